@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/composition/gen"
+)
+
+func main() {
+	watchMode := false
+	cmd := &cobra.Command{
+		Use:   "composition-gen",
+		Short: "Generate Crossplane compositions from Go builders.",
+		Long: `Generate Crossplane compositions from Go builders.
+
+All packages matched by paths are imported into a temporary main package.
+Builders that register themselves using build.Register are built and written
+using the selected output rule. With composition:exported=true, exported
+types implementing build.CompositionBuilder are built with their zero value
+as well.`,
+		Example: `	# Generate all compositions under compositions/ into package/compositions
+	composition-gen paths=./compositions/... output:dir=./package/compositions
+
+	# Fail if the compositions in package/compositions are not up to date
+	composition-gen paths=./compositions/... output:verify=./package/compositions
+
+	# Print the changes compared to the compositions in package/compositions
+	composition-gen paths=./compositions/... output:diff:dir=./package/compositions
+
+	# Only regenerate the compositions of builders tagged with aws
+	composition-gen paths=./compositions/... composition:tags=aws output:dir=./package/compositions
+
+	# Write a JSON report of all compositions, e.g. for auditing unsafe patches
+	composition-gen paths=./compositions/... composition:report=build-report.json output:dir=./package/compositions
+
+	# Regenerate the compositions whenever the builders or the types they use change
+	composition-gen paths=./compositions/... output:dir=./package/compositions --watch
+
+	# Also build exported builder types that have not been registered and print the result to stdout
+	composition-gen paths=./compositions/... composition:exported=true output:stdout
+
+	# Also generate the Configuration package metadata with provider dependencies
+	composition-gen paths=./compositions/... output:dir=./package/compositions \
+		configuration:file=./package/crossplane.yaml,name=my-platform,providers={"*.aws.upbound.io=xpkg.upbound.io/upbound/provider-aws@>=v0.40.0"}
+
+	# Annotate the compositions with their builder and spec hash and mark the files as generated
+	composition-gen paths=./compositions/... composition:provenance=true,header=true output:dir=./package/compositions
+
+	# Print all compositions as a single v1.List
+	composition-gen paths=./compositions/... output:stdout:format=list
+
+	# Lay out the compositions as compositions/<kind>/<variant>.yaml
+	composition-gen paths=./compositions/... output:dir=./package/compositions \
+		composition:fileName="{{ .Kind | lower }}/{{ index .Labels \"variant\" }}.yaml"
+`,
+		RunE: func(c *cobra.Command, rawOpts []string) error {
+			if watchMode {
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				return gen.Watch(ctx, rawOpts, c.OutOrStderr())
+			}
+			return gen.Generate(rawOpts)
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().BoolVar(&watchMode, "watch", false, "regenerate whenever the input packages change")
+
+	if err := cmd.Execute(); err != nil {
+		fmt.Fprintf(cmd.OutOrStderr(), "run `%s %s --help` for usage\n", cmd.CalledAs(), strings.Join(os.Args[1:], " "))
+		os.Exit(1)
+	}
+}
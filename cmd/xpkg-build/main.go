@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/provenance"
+	"github.com/mistermx/crossbuilder/pkg/xpkg"
+)
+
+func main() {
+	root := ""
+	output := ""
+	opts := xpkg.ImageOptions{}
+	verifyProvenance := false
+
+	cmd := &cobra.Command{
+		Use:   "xpkg-build",
+		Short: "Build a Crossplane package from generated manifests.",
+		Long: `Build a Crossplane package from generated manifests.
+
+The crossplane.yaml file and all other YAML files in the package root are
+assembled into a package.yaml stream that is written as an OCI image layout
+tarball. Apart from crossplane.yaml and kustomization files, the package root
+may only contain XRDs and compositions. The package can then be pushed using crossplane xpkg push -f or
+loaded into a local registry. No network access or container runtime is
+required.`,
+		Example: `	# Build the package in ./package
+	xpkg-build --package-root=./package --output=./platform.xpkg
+
+	# Record an image reference in the package
+	xpkg-build --package-root=./package --output=./platform.xpkg --tag=xpkg.upbound.io/my-org/platform:v0.1.0
+
+	# Fail if any generated object has been edited by hand
+	xpkg-build --package-root=./package --output=./platform.xpkg --verify-provenance`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			if verifyProvenance {
+				res, err := provenance.Directory(root)
+				if err != nil {
+					return err
+				}
+				if err := res.Err(); err != nil {
+					return err
+				}
+			}
+			return xpkg.Build(root, output, opts)
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().StringVar(&root, "package-root", "package", "directory that contains crossplane.yaml and the package manifests")
+	cmd.Flags().StringVarP(&output, "output", "o", "package.xpkg", "file the package is written to")
+	cmd.Flags().StringVar(&opts.Tag, "tag", "", "optional image reference recorded in the package")
+	cmd.Flags().BoolVar(&verifyProvenance, "verify-provenance", false, "fail if the spec of a generated object does not match its provenance hash")
+
+	if err := cmd.Execute(); err != nil {
+		fmt.Fprintln(cmd.OutOrStderr(), "run `xpkg-build --help` for usage")
+		os.Exit(1)
+	}
+}
@@ -16,11 +16,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/controller-tools/pkg/deepcopy"
@@ -30,6 +33,7 @@ import (
 	"sigs.k8s.io/controller-tools/pkg/markers"
 	"sigs.k8s.io/controller-tools/pkg/version"
 
+	"github.com/mistermx/crossbuilder/pkg/generate/watch"
 	"github.com/mistermx/crossbuilder/pkg/generate/xrd"
 )
 
@@ -46,7 +50,8 @@ var (
 	// and has options for output forms.
 	allGenerators = map[string]genall.Generator{
 		// "crd": crd.Generator{},
-		"xrd": xrd.Generator{},
+		"xrd":          xrd.Generator{},
+		"claimpreview": xrd.ClaimPreviewGenerator{},
 		// "rbac":        rbac.Generator{},
 		"object": deepcopy.Generator{},
 		// "webhook":     webhook.Generator{},
@@ -59,10 +64,14 @@ var (
 	// - output:<generator>:<form> (per-generator output)
 	// - output:<form> (default output)
 	allOutputRules = map[string]genall.OutputRule{
-		"dir":       genall.OutputToDirectory(""),
+		"dir":       xrd.OutputToDirectory(""),
 		"none":      genall.OutputToNothing,
 		"stdout":    genall.OutputToStdout,
 		"artifacts": genall.OutputArtifacts{},
+		"verify":    xrd.VerifyDirectory(""),
+		"diff":      xrd.DiffDirectory{},
+		"kustomize": xrd.KustomizeDirectory{},
+		"compat":    xrd.CompatDirectory{},
 	}
 
 	// optionsRegistry contains all the marker definitions used to process command line options
@@ -124,6 +133,7 @@ func main() { // nolint:gocyclo
 	helpLevel := 0
 	whichLevel := 0
 	showVersion := false
+	watchMode := false
 
 	cmd := &cobra.Command{
 		Use:   "controller-gen",
@@ -142,6 +152,35 @@ func main() { // nolint:gocyclo
 	# Run all the generators for a given project
 	controller-gen paths=./apis/...
 
+	# Fail if the XRDs in ./package/xrds are not up to date. Without an index
+	# file written by output:xrd:kustomize, the directory must only contain
+	# generated files.
+	controller-gen xrd paths=./apis/... output:xrd:verify=./package/xrds
+
+	# Print the schema changes compared to the XRDs in ./package/xrds as JSON
+	controller-gen xrd paths=./apis/... output:xrd:diff:dir=./package/xrds,format=json
+
+	# Fail if the XRDs contain breaking changes compared to ./package/xrds on origin/main
+	controller-gen xrd paths=./apis/... output:xrd:compat:dir=./package/xrds,revision=origin/main,strict=true
+
+	# Write the XRDs to ./package/xrds and list them in a kustomization.yaml
+	controller-gen xrd paths=./apis/... output:xrd:kustomize:dir=./package/xrds,commonLabels={"app.kubernetes.io/part-of":"platform"}
+
+	# Write the XRDs to ./package/xrds/<group>/<plural>.yaml
+	controller-gen xrd:fileName="{{ .Group }}/{{ .Plural }}.yaml" paths=./apis/... output:xrd:dir=./package/xrds
+
+	# Remove the fields Crossplane injects into composite resources and claims from all XRDs
+	controller-gen xrd:stripCrossplaneFields=true paths=./apis/... output:xrd:dir=./package/xrds
+
+	# Write previews of the claim CRDs, including claim printer columns, to ./docs/claims
+	controller-gen claimpreview paths=./apis/... output:claimpreview:dir=./docs/claims
+
+	# Skip compiling the CEL validation rules like the API server does
+	controller-gen xrd:validateCEL=false paths=./apis/... output:xrd:dir=./package/xrds
+
+	# Regenerate the XRDs whenever the types under apis/ change
+	controller-gen xrd paths=./apis/... output:xrd:dir=./package/xrds --watch
+
 	# Explain the markers for generating CRDs, and their arguments
 	controller-gen crd -ww
 `,
@@ -171,6 +210,15 @@ func main() { // nolint:gocyclo
 				return fmt.Errorf("no generators specified")
 			}
 
+			if watchMode {
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				if err := watch.Generators(ctx, rt, c.OutOrStderr()); err != nil {
+					return noUsageError{err}
+				}
+				return nil
+			}
+
 			if hadErrs := rt.Run(); hadErrs {
 				// don't obscure the actual error with a bunch of usage
 				return noUsageError{fmt.Errorf("not all generators ran successfully")}
@@ -182,6 +230,7 @@ func main() { // nolint:gocyclo
 	cmd.Flags().CountVarP(&whichLevel, "which-markers", "w", "print out all markers available with the requested generators\n(up to -www for the most detailed output, or -wwww for json output)")
 	cmd.Flags().CountVarP(&helpLevel, "detailed-help", "h", "print out more detailed help\n(up to -hhh for the most detailed output, or -hhhh for json output)")
 	cmd.Flags().BoolVar(&showVersion, "version", false, "show version")
+	cmd.Flags().BoolVar(&watchMode, "watch", false, "regenerate whenever the input packages change")
 	cmd.Flags().Bool("help", false, "print out usage and a summary of options")
 	oldUsage := cmd.UsageFunc()
 	cmd.SetUsageFunc(func(c *cobra.Command) error {
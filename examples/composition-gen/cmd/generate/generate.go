@@ -3,16 +3,16 @@ package main
 import (
 	"log"
 
-	"github.com/mistermx/crossbuilder/examples/composition-gen/compositions/example"
 	"github.com/mistermx/crossbuilder/pkg/generate/composition/build"
+
+	// Builders register themselves on import.
+	_ "github.com/mistermx/crossbuilder/examples/composition-gen/compositions/example"
 )
 
 func main() {
 	runner := build.NewRunner(build.RunnerConfig{
-		Writer: build.NewDirectoryWriter("../../package/compositions"),
-		Builder: []build.CompositionBuilder{
-			&example.ExampleBuilder{},
-		},
+		Writer:   build.NewDirectoryWriter("../../package/compositions"),
+		Registry: build.DefaultRegistry,
 	})
 
 	if err := runner.Build(); err != nil {
@@ -10,6 +10,10 @@ import (
 	"github.com/mistermx/crossbuilder/pkg/generate/composition/build"
 )
 
+func init() {
+	build.Register(&ExampleBuilder{}, build.WithGroup("example"), build.WithTags("rbac"))
+}
+
 type ExampleBuilder struct{}
 
 func (b *ExampleBuilder) GetCompositeTypeRef() build.ObjectKindReference {
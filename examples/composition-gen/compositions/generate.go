@@ -0,0 +1,3 @@
+package compositions
+
+//go:generate go run ../../../cmd/composition-gen paths=./... output:dir=../package/compositions
@@ -30,6 +30,7 @@ type XExampleStatus struct {
 // +crossbuilder:generate:xrd:defaultCompositionRef:name=example-composition
 // +crossbuilder:generate:xrd:enforcedCompositionRef:name=example-composition-2
 // +crossbuilder:generate:xrd:connectionSecretKeys={username,password}
+// +crossbuilder:generate:xrd:referenceable
 type XExample struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -0,0 +1,20 @@
+// Package annotations defines the keys of the annotations crossbuilder adds
+// to generated objects. It has no dependencies, so every package that reads
+// or writes these annotations can import it.
+package annotations
+
+const (
+	// Prefix is the prefix of all crossbuilder annotations.
+	Prefix = "crossbuilder.mistermx.github.io/"
+
+	// GeneratorVersion is the version of crossbuilder that generated the
+	// object.
+	GeneratorVersion = Prefix + "generator-version"
+
+	// Source is the Go type the object has been generated from.
+	Source = Prefix + "source"
+
+	// SpecHash is the hash of the spec of the object at the time it has
+	// been generated.
+	SpecHash = Prefix + "spec-hash"
+)
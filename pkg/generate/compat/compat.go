@@ -0,0 +1,347 @@
+// Package compat detects changes between XRD revisions that break existing
+// composite resources and claims.
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	errFmtParseSchema = "failed to parse schema of version %s"
+	errParseXRD       = "failed to parse XRD"
+)
+
+// Severity classifies a change.
+type Severity string
+
+// Severities of changes.
+const (
+	// Compatible changes do not affect existing resources.
+	Compatible Severity = "compatible"
+
+	// Breaking changes may invalidate existing resources.
+	Breaking Severity = "breaking"
+)
+
+// Change is a single schema change of an XRD.
+type Change struct {
+	// XRD is the name of the changed XRD.
+	XRD string `json:"xrd"`
+
+	// Path identifies the changed element, e.g.
+	// versions[v1alpha1].spec.parameters.size.
+	Path string `json:"path"`
+
+	// Severity classifies the change.
+	Severity Severity `json:"severity"`
+
+	// Message describes the change.
+	Message string `json:"message"`
+}
+
+// String returns a human readable representation of this change.
+func (c Change) String() string {
+	if c.Path == "" {
+		return fmt.Sprintf("%s: %s", c.XRD, c.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", c.XRD, c.Path, c.Message)
+}
+
+// version is an XRD version as far as it is relevant for compatibility.
+type version struct {
+	Name   string `json:"name"`
+	Served bool   `json:"served"`
+	Schema *struct {
+		OpenAPIV3Schema json.RawMessage `json:"openAPIV3Schema"`
+	} `json:"schema"`
+}
+
+// xrd is an XRD as far as it is relevant for compatibility.
+type xrd struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Versions []version `json:"versions"`
+	} `json:"spec"`
+}
+
+// schema returns the parsed OpenAPI schema of the version.
+func (v version) schema() (*apiext.JSONSchemaProps, error) {
+	s := &apiext.JSONSchemaProps{}
+	if v.Schema == nil || len(v.Schema.OpenAPIV3Schema) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(v.Schema.OpenAPIV3Schema, s); err != nil {
+		return nil, errors.Wrapf(err, errFmtParseSchema, v.Name)
+	}
+	return s, nil
+}
+
+// XRDs returns the changes between the old and the new revision of an XRD
+// given as YAML or JSON.
+func XRDs(oldXRD, newXRD []byte) ([]Change, error) {
+	oldObj, newObj := &xrd{}, &xrd{}
+	if err := yaml.Unmarshal(oldXRD, oldObj); err != nil {
+		return nil, errors.Wrap(err, errParseXRD)
+	}
+	if err := yaml.Unmarshal(newXRD, newObj); err != nil {
+		return nil, errors.Wrap(err, errParseXRD)
+	}
+	return compare(oldObj, newObj)
+}
+
+// compare returns the changes between the old and the new revision of an
+// XRD.
+func compare(oldXRD, newXRD *xrd) ([]Change, error) {
+	c := &checker{xrd: newXRD.Metadata.Name}
+	newVersions := map[string]version{}
+	for _, v := range newXRD.Spec.Versions {
+		newVersions[v.Name] = v
+	}
+	oldVersions := map[string]bool{}
+	for _, oldV := range oldXRD.Spec.Versions {
+		oldVersions[oldV.Name] = true
+		path := fmt.Sprintf("versions[%s]", oldV.Name)
+		newV, ok := newVersions[oldV.Name]
+		if !ok {
+			c.add(path, Breaking, "version removed")
+			continue
+		}
+		if oldV.Served && !newV.Served {
+			c.add(path, Breaking, "version no longer served")
+		}
+		oldSchema, err := oldV.schema()
+		if err != nil {
+			return nil, err
+		}
+		newSchema, err := newV.schema()
+		if err != nil {
+			return nil, err
+		}
+		c.schema(path, oldSchema, newSchema)
+	}
+	for _, v := range newXRD.Spec.Versions {
+		if !oldVersions[v.Name] {
+			c.add(fmt.Sprintf("versions[%s]", v.Name), Compatible, "version added")
+		}
+	}
+	return c.changes, nil
+}
+
+type checker struct {
+	xrd     string
+	changes []Change
+}
+
+func (c *checker) add(path string, s Severity, format string, args ...interface{}) {
+	c.changes = append(c.changes, Change{
+		XRD:      c.xrd,
+		Path:     path,
+		Severity: s,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// schema compares two schemas recursively.
+func (c *checker) schema(path string, oldS, newS *apiext.JSONSchemaProps) {
+	if oldS.Type != "" && newS.Type != "" && oldS.Type != newS.Type {
+		c.add(path, Breaking, "type changed from %s to %s", oldS.Type, newS.Type)
+		// Nested changes are meaningless for a different type.
+		return
+	}
+	c.enum(path, oldS.Enum, newS.Enum)
+	c.required(path, oldS.Required, newS.Required)
+	c.validation(path, oldS, newS)
+
+	for _, name := range sortedKeys(oldS.Properties) {
+		oldP := oldS.Properties[name]
+		newP, ok := newS.Properties[name]
+		if !ok {
+			c.add(joinPath(path, name), Breaking, "property removed")
+			continue
+		}
+		c.schema(joinPath(path, name), &oldP, &newP)
+	}
+	// New properties are not compared any further: existing resources do not
+	// set them, so nothing below them like required fields can break them.
+	for _, name := range sortedKeys(newS.Properties) {
+		if _, ok := oldS.Properties[name]; !ok {
+			c.add(joinPath(path, name), Compatible, "property added")
+		}
+	}
+
+	if oldS.Items != nil && oldS.Items.Schema != nil && newS.Items != nil && newS.Items.Schema != nil {
+		c.schema(path+"[*]", oldS.Items.Schema, newS.Items.Schema)
+	}
+	if oldS.AdditionalProperties != nil && oldS.AdditionalProperties.Schema != nil &&
+		newS.AdditionalProperties != nil && newS.AdditionalProperties.Schema != nil {
+		c.schema(path+"[*]", oldS.AdditionalProperties.Schema, newS.AdditionalProperties.Schema)
+	}
+}
+
+// enum compares the allowed values of a schema.
+func (c *checker) enum(path string, oldEnum, newEnum []apiext.JSON) {
+	oldValues, newValues := enumValues(oldEnum), enumValues(newEnum)
+	switch {
+	case len(oldValues) == 0 && len(newValues) == 0:
+		return
+	case len(newValues) == 0:
+		c.add(path, Compatible, "enum removed")
+		return
+	case len(oldValues) == 0:
+		c.add(path, Breaking, "enum added: %s", strings.Join(sortedKeys(newValues), ", "))
+		return
+	}
+	removed, added := []string{}, []string{}
+	for _, v := range sortedKeys(oldValues) {
+		if !newValues[v] {
+			removed = append(removed, v)
+		}
+	}
+	for _, v := range sortedKeys(newValues) {
+		if !oldValues[v] {
+			added = append(added, v)
+		}
+	}
+	if len(removed) > 0 {
+		c.add(path, Breaking, "enum narrowed, removed values: %s", strings.Join(removed, ", "))
+	}
+	if len(added) > 0 {
+		c.add(path, Compatible, "enum widened, added values: %s", strings.Join(added, ", "))
+	}
+}
+
+// validation compares the value validations of a schema. Added or
+// tightened validations are breaking, removed or relaxed ones compatible.
+func (c *checker) validation(path string, oldS, newS *apiext.JSONSchemaProps) {
+	bound(c, path, "minimum", oldS.Minimum, newS.Minimum, true)
+	bound(c, path, "maximum", oldS.Maximum, newS.Maximum, false)
+	bound(c, path, "minLength", oldS.MinLength, newS.MinLength, true)
+	bound(c, path, "maxLength", oldS.MaxLength, newS.MaxLength, false)
+	bound(c, path, "minItems", oldS.MinItems, newS.MinItems, true)
+	bound(c, path, "maxItems", oldS.MaxItems, newS.MaxItems, false)
+	bound(c, path, "minProperties", oldS.MinProperties, newS.MinProperties, true)
+	bound(c, path, "maxProperties", oldS.MaxProperties, newS.MaxProperties, false)
+	c.flag(path, "exclusiveMinimum", oldS.ExclusiveMinimum, newS.ExclusiveMinimum)
+	c.flag(path, "exclusiveMaximum", oldS.ExclusiveMaximum, newS.ExclusiveMaximum)
+	c.flag(path, "uniqueItems", oldS.UniqueItems, newS.UniqueItems)
+	if oldS.Nullable && !newS.Nullable {
+		c.add(path, Breaking, "no longer nullable")
+	}
+	c.constraint(path, "pattern", oldS.Pattern, newS.Pattern)
+	c.constraint(path, "format", oldS.Format, newS.Format)
+
+	oldRules, newRules := map[string]bool{}, map[string]bool{}
+	for _, r := range oldS.XValidations {
+		oldRules[r.Rule] = true
+	}
+	for _, r := range newS.XValidations {
+		newRules[r.Rule] = true
+	}
+	for _, r := range sortedKeys(newRules) {
+		if !oldRules[r] {
+			c.add(path, Breaking, "validation rule added: %s", r)
+		}
+	}
+	for _, r := range sortedKeys(oldRules) {
+		if !newRules[r] {
+			c.add(path, Compatible, "validation rule removed: %s", r)
+		}
+	}
+}
+
+// bound compares a lower or upper bound like minimum or maxLength.
+func bound[T int64 | float64](c *checker, path, name string, oldV, newV *T, lower bool) {
+	switch {
+	case oldV == nil && newV == nil:
+	case oldV == nil:
+		c.add(path, Breaking, "%s added: %v", name, *newV)
+	case newV == nil:
+		c.add(path, Compatible, "%s removed", name)
+	case *oldV == *newV:
+	case lower == (*newV > *oldV):
+		c.add(path, Breaking, "%s tightened from %v to %v", name, *oldV, *newV)
+	default:
+		c.add(path, Compatible, "%s relaxed from %v to %v", name, *oldV, *newV)
+	}
+}
+
+// flag compares a validation that applies if it is true, like uniqueItems.
+func (c *checker) flag(path, name string, oldV, newV bool) {
+	switch {
+	case !oldV && newV:
+		c.add(path, Breaking, "%s added", name)
+	case oldV && !newV:
+		c.add(path, Compatible, "%s removed", name)
+	}
+}
+
+// constraint compares a validation whose values cannot be ordered, like
+// pattern. Any change may reject existing values.
+func (c *checker) constraint(path, name, oldV, newV string) {
+	switch {
+	case oldV == newV:
+	case oldV == "":
+		c.add(path, Breaking, "%s added: %q", name, newV)
+	case newV == "":
+		c.add(path, Compatible, "%s removed", name)
+	default:
+		c.add(path, Breaking, "%s changed from %q to %q", name, oldV, newV)
+	}
+}
+
+// required compares the required properties of a schema.
+func (c *checker) required(path string, oldRequired, newRequired []string) {
+	oldSet, newSet := stringSet(oldRequired), stringSet(newRequired)
+	for _, r := range sortedKeys(newSet) {
+		if !oldSet[r] {
+			c.add(joinPath(path, r), Breaking, "property became required")
+		}
+	}
+	for _, r := range sortedKeys(oldSet) {
+		if !newSet[r] {
+			c.add(joinPath(path, r), Compatible, "property no longer required")
+		}
+	}
+}
+
+func enumValues(enum []apiext.JSON) map[string]bool {
+	values := make(map[string]bool, len(enum))
+	for _, v := range enum {
+		values[string(v.Raw)] = true
+	}
+	return values
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, segment string) string {
+	if path == "" {
+		return segment
+	}
+	return path + "." + segment
+}
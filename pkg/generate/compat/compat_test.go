@@ -0,0 +1,197 @@
+package compat
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// xrdYAML returns an XRD named xtests.example.org with a single version
+// v1alpha1 that has the given schema of spec.size.
+func xrdYAML(sizeSchema string) []byte {
+	return []byte(fmt.Sprintf(`apiVersion: apiextensions.crossplane.io/v1
+kind: CompositeResourceDefinition
+metadata:
+  name: xtests.example.org
+spec:
+  versions:
+  - name: v1alpha1
+    served: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              size: %s
+`, sizeSchema))
+}
+
+func TestXRDs(t *testing.T) {
+	const path = "versions[v1alpha1].spec.size"
+	cases := map[string]struct {
+		reason string
+		old    string
+		new    string
+		want   []Change
+	}{
+		"Unchanged": {
+			reason: "Equal schemas have no changes.",
+			old:    `{type: integer, minimum: 1}`,
+			new:    `{type: integer, minimum: 1}`,
+		},
+		"TypeChanged": {
+			reason: "Changing the type is breaking.",
+			old:    `{type: integer}`,
+			new:    `{type: string}`,
+			want:   []Change{{Path: path, Severity: Breaking, Message: "type changed from integer to string"}},
+		},
+		"EnumNarrowed": {
+			reason: "Removing enum values is breaking, adding them compatible.",
+			old:    `{type: string, enum: [small, large]}`,
+			new:    `{type: string, enum: [small, medium]}`,
+			want: []Change{
+				{Path: path, Severity: Breaking, Message: `enum narrowed, removed values: "large"`},
+				{Path: path, Severity: Compatible, Message: `enum widened, added values: "medium"`},
+			},
+		},
+		"MinimumAdded": {
+			reason: "Adding a minimum is breaking.",
+			old:    `{type: integer}`,
+			new:    `{type: integer, minimum: 1}`,
+			want:   []Change{{Path: path, Severity: Breaking, Message: "minimum added: 1"}},
+		},
+		"MinimumTightened": {
+			reason: "Raising a minimum is breaking.",
+			old:    `{type: integer, minimum: 1}`,
+			new:    `{type: integer, minimum: 2}`,
+			want:   []Change{{Path: path, Severity: Breaking, Message: "minimum tightened from 1 to 2"}},
+		},
+		"MaximumRelaxed": {
+			reason: "Raising a maximum is compatible.",
+			old:    `{type: integer, maximum: 10}`,
+			new:    `{type: integer, maximum: 20}`,
+			want:   []Change{{Path: path, Severity: Compatible, Message: "maximum relaxed from 10 to 20"}},
+		},
+		"MaxLengthTightened": {
+			reason: "Lowering a maximum length is breaking.",
+			old:    `{type: string, maxLength: 64}`,
+			new:    `{type: string, maxLength: 32}`,
+			want:   []Change{{Path: path, Severity: Breaking, Message: "maxLength tightened from 64 to 32"}},
+		},
+		"MinItemsRemoved": {
+			reason: "Removing a bound is compatible.",
+			old:    `{type: array, minItems: 1, items: {type: string}}`,
+			new:    `{type: array, items: {type: string}}`,
+			want:   []Change{{Path: path, Severity: Compatible, Message: "minItems removed"}},
+		},
+		"PatternChanged": {
+			reason: "Changing a pattern is breaking.",
+			old:    `{type: string, pattern: "^[a-z]+$"}`,
+			new:    `{type: string, pattern: "^[a-z0-9]+$"}`,
+			want:   []Change{{Path: path, Severity: Breaking, Message: `pattern changed from "^[a-z]+$" to "^[a-z0-9]+$"`}},
+		},
+		"FormatAdded": {
+			reason: "Adding a format is breaking.",
+			old:    `{type: string}`,
+			new:    `{type: string, format: date-time}`,
+			want:   []Change{{Path: path, Severity: Breaking, Message: `format added: "date-time"`}},
+		},
+		"UniqueItemsAdded": {
+			reason: "Requiring unique items is breaking.",
+			old:    `{type: array, items: {type: string}}`,
+			new:    `{type: array, uniqueItems: true, items: {type: string}}`,
+			want:   []Change{{Path: path, Severity: Breaking, Message: "uniqueItems added"}},
+		},
+		"NoLongerNullable": {
+			reason: "Removing nullable is breaking.",
+			old:    `{type: string, nullable: true}`,
+			new:    `{type: string}`,
+			want:   []Change{{Path: path, Severity: Breaking, Message: "no longer nullable"}},
+		},
+		"ValidationRules": {
+			reason: "Adding a CEL rule is breaking, removing one compatible.",
+			old:    `{type: integer, x-kubernetes-validations: [{rule: "self > 0"}]}`,
+			new:    `{type: integer, x-kubernetes-validations: [{rule: "self > 1"}]}`,
+			want: []Change{
+				{Path: path, Severity: Breaking, Message: "validation rule added: self > 1"},
+				{Path: path, Severity: Compatible, Message: "validation rule removed: self > 0"},
+			},
+		},
+		"NestedPropertyRemoved": {
+			reason: "Removing a property is breaking, adding one compatible.",
+			old:    `{type: object, properties: {gb: {type: integer}}}`,
+			new:    `{type: object, properties: {gib: {type: integer}}}`,
+			want: []Change{
+				{Path: path + ".gb", Severity: Breaking, Message: "property removed"},
+				{Path: path + ".gib", Severity: Compatible, Message: "property added"},
+			},
+		},
+		"Required": {
+			reason: "Newly required properties are breaking.",
+			old:    `{type: object, properties: {gb: {type: integer}}}`,
+			new:    `{type: object, required: [gb], properties: {gb: {type: integer}}}`,
+			want:   []Change{{Path: path + ".gb", Severity: Breaking, Message: "property became required"}},
+		},
+		"RequiredBelowNewProperty": {
+			reason: "Required fields of a new optional property cannot break existing resources.",
+			old:    `{type: object, properties: {gb: {type: integer}}}`,
+			new:    `{type: object, properties: {gb: {type: integer}, disk: {type: object, required: [size], properties: {size: {type: integer}}}}}`,
+			want:   []Change{{Path: path + ".disk", Severity: Compatible, Message: "property added"}},
+		},
+		"RequiredNewProperty": {
+			reason: "A new required property is breaking because existing resources do not set it.",
+			old:    `{type: object, properties: {gb: {type: integer}}}`,
+			new:    `{type: object, required: [disk], properties: {gb: {type: integer}, disk: {type: string}}}`,
+			want: []Change{
+				{Path: path + ".disk", Severity: Breaking, Message: "property became required"},
+				{Path: path + ".disk", Severity: Compatible, Message: "property added"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := XRDs(xrdYAML(tc.old), xrdYAML(tc.new))
+			if err != nil {
+				t.Fatalf("\n%s\nXRDs(...): %v", tc.reason, err)
+			}
+			for i := range tc.want {
+				tc.want[i].XRD = "xtests.example.org"
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nXRDs(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestXRDsVersions(t *testing.T) {
+	oldXRD := []byte(`kind: CompositeResourceDefinition
+metadata: {name: xtests.example.org}
+spec:
+  versions:
+  - {name: v1alpha1, served: true}
+  - {name: v1beta1, served: true}
+`)
+	newXRD := []byte(`kind: CompositeResourceDefinition
+metadata: {name: xtests.example.org}
+spec:
+  versions:
+  - {name: v1beta1, served: false}
+  - {name: v1, served: true}
+`)
+	got, err := XRDs(oldXRD, newXRD)
+	if err != nil {
+		t.Fatalf("XRDs(...): %v", err)
+	}
+	want := []Change{
+		{XRD: "xtests.example.org", Path: "versions[v1alpha1]", Severity: Breaking, Message: "version removed"},
+		{XRD: "xtests.example.org", Path: "versions[v1beta1]", Severity: Breaking, Message: "version no longer served"},
+		{XRD: "xtests.example.org", Path: "versions[v1]", Severity: Compatible, Message: "version added"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("XRDs(...): -want, +got:\n%s", diff)
+	}
+}
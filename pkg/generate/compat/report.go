@@ -0,0 +1,222 @@
+package compat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/diff"
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+	"github.com/mistermx/crossbuilder/pkg/generate/verify"
+)
+
+const (
+	errFmtReadFile   = "failed to read %s"
+	errFmtParseFile  = "failed to parse %s"
+	errFmtFormat     = "unknown report format %q"
+	errFmtGitListing = "failed to list files of revision %s"
+	errFmtGitShow    = "failed to read %s of revision %s"
+
+	kindXRD = "CompositeResourceDefinition"
+)
+
+// Report contains the changes between two sets of XRDs.
+type Report struct {
+	Changes []Change `json:"changes"`
+}
+
+// Breaking returns all breaking changes.
+func (r *Report) Breaking() []Change {
+	breaking := []Change{}
+	for _, c := range r.Changes {
+		if c.Severity == Breaking {
+			breaking = append(breaking, c)
+		}
+	}
+	return breaking
+}
+
+// Err returns an *Error if the report contains breaking changes.
+func (r *Report) Err() error {
+	breaking := r.Breaking()
+	if len(breaking) == 0 {
+		return nil
+	}
+	return &Error{Changes: breaking}
+}
+
+// Write writes this report in the given format.
+func (r *Report) Write(w io.Writer, format diff.Format) error {
+	switch format {
+	case diff.FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case diff.FormatText, "":
+		return r.writeText(w)
+	}
+	return errors.Errorf(errFmtFormat, format)
+}
+
+func (r *Report) writeText(w io.Writer) error {
+	if len(r.Changes) == 0 {
+		_, err := fmt.Fprintln(w, "no schema changes")
+		return err
+	}
+	for _, c := range r.Changes {
+		if _, err := fmt.Fprintf(w, "%-10s %s\n", c.Severity, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Error is returned if XRDs contain breaking changes.
+type Error struct {
+	Changes []Change
+}
+
+func (e *Error) Error() string {
+	b := &strings.Builder{}
+	b.WriteString("XRDs contain breaking changes:")
+	for _, c := range e.Changes {
+		fmt.Fprintf(b, "\n  %s", c)
+	}
+	return b.String()
+}
+
+// Options configure how XRDs are compared.
+type Options struct {
+	// Removed reports XRDs that exist in the compared files but have not
+	// been generated as breaking change. Only enable it if the generated
+	// XRDs are complete, i.e. the generator loaded all API packages.
+	Removed bool
+}
+
+// FS compares the XRDs in the generated files with the XRDs in the YAML
+// files of fsys. XRDs are matched by name, so files may have been renamed.
+// XRDs of fsys that have not been generated are ignored unless
+// opts.Removed is set.
+func FS(fsys fs.FS, generated map[string][]byte, opts Options) (*Report, error) {
+	oldXRDs, err := readXRDs(fsys)
+	if err != nil {
+		return nil, err
+	}
+	newXRDs := map[string]*xrd{}
+	for _, name := range sortedKeys(generated) {
+		if err := parseXRDs(name, generated[name], newXRDs); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Report{}
+	for _, name := range sortedKeys(oldXRDs) {
+		newXRD, ok := newXRDs[name]
+		if !ok {
+			if opts.Removed {
+				report.Changes = append(report.Changes, Change{XRD: name, Severity: Breaking, Message: "XRD removed"})
+			}
+			continue
+		}
+		changes, err := compare(oldXRDs[name], newXRD)
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		report.Changes = append(report.Changes, changes...)
+	}
+	for _, name := range sortedKeys(newXRDs) {
+		if _, ok := oldXRDs[name]; !ok {
+			report.Changes = append(report.Changes, Change{XRD: name, Severity: Compatible, Message: "XRD added"})
+		}
+	}
+	sort.SliceStable(report.Changes, func(i, j int) bool {
+		return report.Changes[i].XRD < report.Changes[j].XRD
+	})
+	return report, nil
+}
+
+// GitRevision returns the files in dir at the given git revision, e.g.
+// HEAD or origin/main, as an in-memory file system. dir must be inside of a
+// git work tree.
+func GitRevision(dir, rev string) (fs.FS, error) {
+	out, err := git(dir, "ls-tree", "-r", "--name-only", rev, ".")
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtGitListing, rev)
+	}
+	mem := filesystem.NewMemory()
+	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if name == "" || !verify.IsYAMLFile(name) {
+			continue
+		}
+		content, err := git(dir, "show", rev+":./"+name)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtGitShow, name, rev)
+		}
+		if err := mem.WriteFile(name, content); err != nil {
+			return nil, err
+		}
+	}
+	return mem, nil
+}
+
+// git runs git in dir and returns its standard output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// readXRDs returns all XRDs in the YAML files of fsys keyed by name.
+func readXRDs(fsys fs.FS) (map[string]*xrd, error) {
+	files, err := verify.ExistingFilesFS(fsys, verify.IsYAMLFile)
+	if err != nil {
+		return nil, err
+	}
+	xrds := map[string]*xrd{}
+	for _, f := range files {
+		content, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtReadFile, f)
+		}
+		if err := parseXRDs(f, content, xrds); err != nil {
+			return nil, err
+		}
+	}
+	return xrds, nil
+}
+
+// parseXRDs adds all XRDs in the given YAML stream to xrds.
+func parseXRDs(file string, content []byte, xrds map[string]*xrd) error {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, errFmtParseFile, file)
+		}
+		obj := &xrd{}
+		if err := yaml.Unmarshal(doc, obj); err != nil {
+			return errors.Wrapf(err, errFmtParseFile, file)
+		}
+		if obj.Kind == kindXRD {
+			xrds[obj.Metadata.Name] = obj
+		}
+	}
+}
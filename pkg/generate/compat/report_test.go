@@ -0,0 +1,65 @@
+package compat
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFS(t *testing.T) {
+	xrd := func(name string) []byte {
+		return []byte("kind: CompositeResourceDefinition\nmetadata: {name: " + name + "}\nspec:\n  versions:\n  - {name: v1alpha1, served: true}\n")
+	}
+	fsys := fstest.MapFS{
+		"a.yaml":         {Data: xrd("xas.example.org")},
+		"other/b.yaml":   {Data: xrd("xbs.example.org")},
+		"configmap.yaml": {Data: []byte("kind: ConfigMap\nmetadata: {name: c}\n")},
+	}
+	generated := map[string][]byte{
+		"renamed.yaml": xrd("xas.example.org"),
+		"c.yaml":       xrd("xcs.example.org"),
+	}
+
+	cases := map[string]struct {
+		reason string
+		opts   Options
+		want   []Change
+	}{
+		"GeneratedOnly": {
+			reason: "XRDs that have not been generated, e.g. because paths selects a subset of the API packages, must be ignored.",
+			want:   []Change{{XRD: "xcs.example.org", Severity: Compatible, Message: "XRD added"}},
+		},
+		"Removed": {
+			reason: "With Removed, XRDs that have not been generated are breaking changes.",
+			opts:   Options{Removed: true},
+			want: []Change{
+				{XRD: "xbs.example.org", Severity: Breaking, Message: "XRD removed"},
+				{XRD: "xcs.example.org", Severity: Compatible, Message: "XRD added"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := FS(fsys, generated, tc.opts)
+			if err != nil {
+				t.Fatalf("\n%s\nFS(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got.Changes); diff != "" {
+				t.Errorf("\n%s\nFS(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReportErr(t *testing.T) {
+	r := &Report{Changes: []Change{{XRD: "a", Severity: Compatible, Message: "XRD added"}}}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err(): want nil for compatible changes, got %v", err)
+	}
+	r.Changes = append(r.Changes, Change{XRD: "b", Severity: Breaking, Message: "XRD removed"})
+	want := "XRDs contain breaking changes:\n  b: XRD removed"
+	if err := r.Err(); err == nil || err.Error() != want {
+		t.Errorf("Err(): want %q, got %v", want, err)
+	}
+}
@@ -1,13 +1,33 @@
 package build
 
 import (
+	"fmt"
+	"io"
+	"reflect"
+	goruntime "runtime"
+	"sort"
+	"strings"
+	"sync"
+
 	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/provenance"
 )
 
 const (
-	errWriteComposition    = "failed to write composition"
-	errFmtBuildComposition = "failed to build composition at index %d"
+	errWriteComposition     = "failed to write composition"
+	errWriteObject          = "failed to write object"
+	errFinalizeWriter       = "failed to finalize output"
+	errFmtBuildBuilder      = "builder %s"
+	errFmtBuildCompositions = "failed to build %d composition(s): [%s]"
+	errFmtDuplicateName     = "composition name %q is used by builders %s and %s"
+	errFmtMutateSkeleton    = "mutator at index %d failed to mutate skeleton"
+	errFmtMutateComposition = "mutator at index %d failed to mutate composition"
+	errBuildObjects         = "failed to build objects"
+	errFmtUnnamedBuilders   = "cannot filter by composition name: builders %s do not implement CompositionNamer"
 )
 
 // CompositionBuilder specifies the interface for user defined type that is
@@ -21,10 +41,44 @@ type CompositionBuilder interface {
 	Build(composition CompositionSkeleton)
 }
 
+// ObjectBuilder can be implemented by CompositionBuilders that produce
+// objects in addition to their composition, e.g. EnvironmentConfigs,
+// example claims or Usages. The objects are written by the same writer as
+// the composition.
+type ObjectBuilder interface {
+	// BuildObjects returns the additional objects. Their apiVersion and
+	// kind must be set.
+	BuildObjects() ([]client.Object, error)
+}
+
 // RunnerConfig specifies a new composition runner config.
 type RunnerConfig struct {
 	Builder []CompositionBuilder
 	Writer  CompositionWriter
+
+	// Registry is an optional builder registry. If set, all builders
+	// registered with it are built in addition to Builder. Registered
+	// builders of a type that is listed in Builder are skipped.
+	Registry *Registry
+
+	// Parallelism is the maximum number of compositions that are built
+	// concurrently. Defaults to the number of usable CPUs if not set.
+	Parallelism int
+
+	// Filter selects the builders to build. All builders are built if it
+	// is empty.
+	Filter BuilderFilter
+
+	// Mutators are applied in order to the composition of every builder.
+	Mutators []CompositionMutator
+
+	// Report is an optional writer a JSON build report is written to.
+	Report io.Writer
+
+	// Provenance adds the generator version, the Go type of the builder
+	// and the hash of the spec as annotations to every composition and
+	// every object of an ObjectBuilder. See package provenance.
+	Provenance bool
 }
 
 // CompositionBuildRunner specifies the interface for a composition builder.
@@ -47,25 +101,262 @@ type compositionBuildRunner struct {
 
 // Build generates all compositions from the builders and sends them to the
 // output writer.
+// Compositions are built concurrently but always written in the order of
+// their builders. If any builder fails nothing is written and a *BuildError
+// containing all failures is returned.
 func (b *compositionBuildRunner) Build() error {
-	compositions := make([]xapiextv1.Composition, len(b.config.Builder))
+	if err := b.config.Filter.Validate(); err != nil {
+		return err
+	}
+	builders, err := b.builders()
+	if err != nil {
+		return err
+	}
+	built, err := b.buildCompositions(builders)
+	if err != nil {
+		return err
+	}
+	if err := checkDuplicateNames(built); err != nil {
+		return err
+	}
+	if b.config.Report != nil {
+		if err := buildReport(built).Write(b.config.Report); err != nil {
+			return err
+		}
+	}
+
+	ow, isObjectWriter := b.config.Writer.(ObjectWriter)
+	if isObjectWriter && !b.config.Filter.IsEmpty() {
+		ow.MarkPartial()
+	}
+	for _, bc := range built {
+		if err := writeObject(b.config.Writer, &bc.composition, bc.builder); err != nil {
+			return errors.Wrap(err, errWriteComposition)
+		}
+		for _, obj := range bc.objects {
+			if err := writeObject(b.config.Writer, obj, bc.builder); err != nil {
+				return errors.Wrap(err, errWriteObject)
+			}
+		}
+	}
+	if isObjectWriter {
+		return errors.Wrap(ow.Finalize(), errFinalizeWriter)
+	}
+	return nil
+}
+
+// builders returns the builders of the config followed by the builders of
+// the registry that match the filter. Registered builders of a type that is
+// also listed in the config are skipped, so builders may be registered and
+// passed explicitly at the same time.
+// If the filter selects composition names, all builders that match the
+// other criteria must implement CompositionNamer.
+func (b *compositionBuildRunner) builders() ([]RegisteredBuilder, error) {
+	all := make([]RegisteredBuilder, len(b.config.Builder))
+	configTypes := make(map[reflect.Type]bool, len(b.config.Builder))
 	for i, builder := range b.config.Builder {
-		compSkeleton := &compositionSkeleton{
-			composite: builder.GetCompositeTypeRef(),
+		all[i] = RegisteredBuilder{Builder: builder}
+		configTypes[reflect.TypeOf(builder)] = true
+	}
+	if b.config.Registry != nil {
+		for _, rb := range b.config.Registry.Entries() {
+			if !configTypes[reflect.TypeOf(rb.Builder)] {
+				all = append(all, rb)
+			}
+		}
+	}
+
+	builders := []RegisteredBuilder{}
+	unnamed := []string{}
+	for _, rb := range all {
+		if !b.config.Filter.MatchesBuilder(rb) {
+			continue
 		}
-		builder.Build(compSkeleton)
+		if len(b.config.Filter.Names) > 0 {
+			namer, ok := rb.Builder.(CompositionNamer)
+			if !ok {
+				unnamed = append(unnamed, BuilderName(rb.Builder))
+				continue
+			}
+			if !b.config.Filter.MatchesName(namer.GetCompositionName()) {
+				continue
+			}
+		}
+		builders = append(builders, rb)
+	}
+	if len(unnamed) > 0 {
+		return nil, errors.Errorf(errFmtUnnamedBuilders, strings.Join(unnamed, ", "))
+	}
+	return builders, nil
+}
+
+// builtComposition is a composition and the additional objects together
+// with the builder that built them.
+type builtComposition struct {
+	builder     RegisteredBuilder
+	composition xapiextv1.Composition
+	objects     []client.Object
+	report      CompositionReport
+}
+
+func buildReport(built []builtComposition) *Report {
+	r := &Report{
+		Compositions: make([]CompositionReport, len(built)),
+	}
+	for i, bc := range built {
+		r.Compositions[i] = bc.report
+	}
+	return r
+}
 
-		comp, err := compSkeleton.ToComposition()
+// buildCompositions builds the compositions of all builders using at most
+// the configured number of workers. The result has the same order as
+// builders.
+func (b *compositionBuildRunner) buildCompositions(builders []RegisteredBuilder) ([]builtComposition, error) {
+	parallelism := b.config.Parallelism
+	if parallelism <= 0 {
+		parallelism = goruntime.GOMAXPROCS(0)
+	}
+
+	compositions := make([]builtComposition, len(builders))
+	errs := make([]error, len(builders))
+
+	indices := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < parallelism && w < len(builders); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				compositions[i], errs[i] = b.buildComposition(builders[i])
+			}
+		}()
+	}
+	for i := range builders {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	buildErr := &BuildError{}
+	for i, err := range errs {
 		if err != nil {
-			return errors.Wrapf(err, errFmtBuildComposition, i)
+			buildErr.add(BuilderName(builders[i].Builder), err)
 		}
-		compositions[i] = comp
 	}
+	if len(buildErr.Errors) > 0 {
+		return nil, buildErr
+	}
+	return compositions, nil
+}
 
-	for _, comp := range compositions {
-		if err := b.config.Writer.Write(comp); err != nil {
-			return errors.Wrap(err, errWriteComposition)
+// buildComposition runs a single builder and the mutators and converts the
+// result into a composition.
+func (b *compositionBuildRunner) buildComposition(builder RegisteredBuilder) (builtComposition, error) {
+	compSkeleton := &compositionSkeleton{
+		composite: builder.Builder.GetCompositeTypeRef(),
+	}
+	builder.Builder.Build(compSkeleton)
+
+	for i, m := range b.config.Mutators {
+		if err := m.MutateSkeleton(compSkeleton); err != nil {
+			return builtComposition{}, errors.Wrapf(err, errFmtMutateSkeleton, i)
+		}
+	}
+	comp, err := compSkeleton.ToComposition()
+	if err != nil {
+		return builtComposition{}, err
+	}
+	for i, m := range b.config.Mutators {
+		if err := m.MutateComposition(&comp); err != nil {
+			return builtComposition{}, errors.Wrapf(err, errFmtMutateComposition, i)
+		}
+	}
+	objects := []client.Object{}
+	if ob, ok := builder.Builder.(ObjectBuilder); ok {
+		if objects, err = ob.BuildObjects(); err != nil {
+			return builtComposition{}, errors.Wrap(err, errBuildObjects)
 		}
 	}
+	if b.config.Provenance {
+		source := provenance.TypeName(builder.Builder)
+		if err := provenance.Annotate(&comp, source, comp.Spec); err != nil {
+			return builtComposition{}, err
+		}
+		for _, obj := range objects {
+			if err := annotateObject(obj, source); err != nil {
+				return builtComposition{}, err
+			}
+		}
+	}
+	return builtComposition{
+		builder:     builder,
+		composition: comp,
+		objects:     objects,
+		report:      compSkeleton.report(BuilderName(builder.Builder)),
+	}, nil
+}
+
+// annotateObject sets the provenance annotations of an object built by an
+// ObjectBuilder.
+func annotateObject(obj client.Object, source string) error {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	return provenance.Annotate(obj, source, u["spec"])
+}
+
+// checkDuplicateNames returns an error if two builders produced compositions
+// with the same name.
+func checkDuplicateNames(built []builtComposition) error {
+	seen := make(map[string]int, len(built))
+	for i, bc := range built {
+		name := bc.composition.GetName()
+		if j, exists := seen[name]; exists {
+			return errors.Errorf(errFmtDuplicateName, name, BuilderName(built[j].builder.Builder), BuilderName(bc.builder.Builder))
+		}
+		seen[name] = i
+	}
 	return nil
 }
+
+// BuilderName returns the name that identifies the given builder in errors,
+// which is the name of its Go type.
+func BuilderName(builder CompositionBuilder) string {
+	return reflect.TypeOf(builder).String()
+}
+
+// BuildError is returned by the runner if one or more compositions could not
+// be built.
+type BuildError struct {
+	// Errors contains the build failures keyed by the type name of the
+	// builder that failed.
+	Errors map[string]error
+}
+
+func (e *BuildError) add(builder string, err error) {
+	if e.Errors == nil {
+		e.Errors = map[string]error{}
+	}
+	if prev, ok := e.Errors[builder]; ok {
+		// Multiple builders of the same type failed.
+		err = errors.Errorf("%v; %v", prev, err)
+	}
+	e.Errors[builder] = err
+}
+
+// Error returns all errors sorted by builder name.
+func (e *BuildError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = errors.Wrapf(e.Errors[name], errFmtBuildBuilder, name).Error()
+	}
+	return fmt.Sprintf(errFmtBuildCompositions, len(e.Errors), strings.Join(msgs, ", "))
+}
@@ -0,0 +1,126 @@
+package build
+
+import (
+	"fmt"
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// testBuilder builds an empty composition with the given name.
+type testBuilder struct {
+	name string
+	kind string
+}
+
+func (b testBuilder) GetCompositeTypeRef() ObjectKindReference {
+	kind := b.kind
+	if kind == "" {
+		kind = "XTest"
+	}
+	return ObjectKindReference{
+		GroupVersionKind: schema.GroupVersionKind{Group: "example.org", Version: "v1alpha1", Kind: kind},
+		Object:           &unstructured.Unstructured{},
+	}
+}
+
+func (b testBuilder) Build(c CompositionSkeleton) {
+	c.WithName(b.name)
+}
+
+// failingBuilder builds a composition without a name, which cannot be
+// converted.
+type failingBuilder struct{}
+
+func (failingBuilder) GetCompositeTypeRef() ObjectKindReference {
+	return testBuilder{}.GetCompositeTypeRef()
+}
+
+func (failingBuilder) Build(CompositionSkeleton) {}
+
+// recordingWriter records the names of all written compositions.
+type recordingWriter struct {
+	names []string
+}
+
+func (w *recordingWriter) Write(c xapiextv1.Composition) error {
+	w.names = append(w.names, c.GetName())
+	return nil
+}
+
+func TestBuildOrder(t *testing.T) {
+	builders := make([]CompositionBuilder, 50)
+	want := make([]string, len(builders))
+	for i := range builders {
+		want[i] = fmt.Sprintf("composition-%02d", i)
+		builders[i] = testBuilder{name: want[i]}
+	}
+
+	for _, parallelism := range []int{0, 1, 4, 100} {
+		w := &recordingWriter{}
+		err := NewRunner(RunnerConfig{
+			Builder:     builders,
+			Writer:      w,
+			Parallelism: parallelism,
+		}).Build()
+		if err != nil {
+			t.Fatalf("Build() with parallelism %d: %v", parallelism, err)
+		}
+		if diff := cmp.Diff(want, w.names); diff != "" {
+			t.Errorf("Build() with parallelism %d: -want, +got:\n%s", parallelism, diff)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	w := &recordingWriter{}
+	err := NewRunner(RunnerConfig{
+		Builder: []CompositionBuilder{
+			testBuilder{name: "a"},
+			failingBuilder{},
+			testBuilder{name: "b"},
+			failingBuilder{},
+		},
+		Writer: w,
+	}).Build()
+
+	buildErr, ok := err.(*BuildError)
+	if !ok {
+		t.Fatalf("Build(): want *BuildError, got %T: %v", err, err)
+	}
+	if diff := cmp.Diff([]string{"build.failingBuilder"}, sortedKeys(errorSet(buildErr))); diff != "" {
+		t.Errorf("Build(): failed builders: -want, +got:\n%s", diff)
+	}
+	want := "failed to build 1 composition(s): [builder build.failingBuilder: " + errEmptyCompositionname + "; " + errEmptyCompositionname + "]"
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Errorf("Build(): -want, +got error:\n%s", diff)
+	}
+	if len(w.names) > 0 {
+		t.Errorf("Build(): wrote %v although builders failed", w.names)
+	}
+}
+
+func TestBuildDuplicateNames(t *testing.T) {
+	err := NewRunner(RunnerConfig{
+		Builder: []CompositionBuilder{
+			testBuilder{name: "a"},
+			testBuilder{name: "a", kind: "XOther"},
+		},
+		Writer: &recordingWriter{},
+	}).Build()
+	want := fmt.Sprintf(errFmtDuplicateName, "a", "build.testBuilder", "build.testBuilder")
+	if err == nil || err.Error() != want {
+		t.Errorf("Build(): want error %q, got %v", want, err)
+	}
+}
+
+func errorSet(e *BuildError) map[string]bool {
+	set := map[string]bool{}
+	for name := range e.Errors {
+		set[name] = true
+	}
+	return set
+}
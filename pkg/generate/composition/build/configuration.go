@@ -0,0 +1,342 @@
+package build
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	xpmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+)
+
+const (
+	errFmtUnmappedGroups     = "no provider mapped for API group(s) %s"
+	errFmtConflictingVersion = "conflicting version constraints %q and %q for provider %s"
+	errFmtInvalidVersion     = "invalid Crossplane version %q"
+	errParseBase             = "failed to parse composed resource base"
+	errWriteConfiguration    = "failed to write configuration"
+
+	// DefaultConfigurationFileName is the file name Crossplane expects the
+	// package metadata in.
+	DefaultConfigurationFileName = "crossplane.yaml"
+
+	// defaultCrossplaneVersion is the minimum Crossplane version if no
+	// composition requires a newer one.
+	defaultCrossplaneVersion = "v1.0.0"
+)
+
+// ProviderMapping maps API groups to the provider package that serves them.
+type ProviderMapping struct {
+	// Group is a pattern for API groups as accepted by path.Match, e.g.
+	// ec2.aws.upbound.io or *.aws.upbound.io.
+	Group string
+
+	// Provider is the provider package, e.g.
+	// xpkg.upbound.io/upbound/provider-aws-ec2. Matching groups do not
+	// add a dependency if it is empty.
+	Provider string
+
+	// Version is the version constraint of the provider, e.g. >=v0.40.0.
+	Version string
+}
+
+// ConfigurationOptions configure the Configuration written by a
+// ConfigurationWriter.
+type ConfigurationOptions struct {
+	// Name is the name of the Configuration.
+	Name string
+
+	// Annotations are added to the Configuration.
+	Annotations map[string]string
+
+	// Providers map the API groups of composed resources to provider
+	// packages. The first matching mapping is used.
+	Providers []ProviderMapping
+
+	// CrossplaneVersion is the minimum Crossplane version. It is raised if
+	// the compositions use features that require a newer version.
+	CrossplaneVersion string
+
+	// FileSystem is the file system the file is written to. The file is
+	// then a slash separated path of it. Defaults to the local file system.
+	FileSystem filesystem.FS
+}
+
+// crossplaneFeature is a composition feature that requires a minimum
+// Crossplane version.
+type crossplaneFeature struct {
+	version string
+	usedBy  func(c xapiextv1.Composition) bool
+}
+
+var crossplaneFeatures = []crossplaneFeature{
+	{
+		// External secret stores.
+		version: "v1.7.0",
+		usedBy: func(c xapiextv1.Composition) bool {
+			return c.Spec.PublishConnectionDetailsWithStoreConfigRef != nil
+		},
+	},
+	{
+		// Environment configs.
+		version: "v1.11.0",
+		usedBy: func(c xapiextv1.Composition) bool {
+			if c.Spec.Environment != nil {
+				return true
+			}
+			for _, r := range c.Spec.Resources {
+				for _, p := range r.Patches {
+					switch p.Type { //nolint:exhaustive
+					case xapiextv1.PatchTypeFromEnvironmentFieldPath,
+						xapiextv1.PatchTypeToEnvironmentFieldPath,
+						xapiextv1.PatchTypeCombineFromEnvironment,
+						xapiextv1.PatchTypeCombineToEnvironment:
+						return true
+					}
+				}
+			}
+			return false
+		},
+	},
+	{
+		// Composition functions.
+		version: "v1.14.0",
+		usedBy: func(c xapiextv1.Composition) bool {
+			return c.Spec.Mode != nil && *c.Spec.Mode == xapiextv1.CompositionModePipeline
+		},
+	},
+}
+
+// NewConfigurationWriter creates a CompositionWriter that does not write
+// compositions itself but generates the Crossplane Configuration package
+// metadata for them and writes it to the given file on Finalize. Combine it
+// with other writers using NewMultiWriter.
+//
+// The dependencies of the Configuration are derived from the API groups of
+// all composed resources. Groups of composite types of the written
+// compositions are part of the package and ignored. The output only depends
+// on the set of written compositions, so repeated runs produce the same file.
+//
+// Nothing is written if only a subset of all compositions is built.
+func NewConfigurationWriter(file string, opts ConfigurationOptions) CompositionWriter {
+	return &configurationWriter{
+		file:       file,
+		opts:       opts,
+		groups:     map[string]bool{},
+		composites: map[string]bool{},
+		crossplane: opts.CrossplaneVersion,
+	}
+}
+
+type configurationWriter struct {
+	file    string
+	opts    ConfigurationOptions
+	partial bool
+
+	// groups are the API groups of all composed resources.
+	groups map[string]bool
+
+	// composites are the groups of the composite types.
+	composites map[string]bool
+
+	// crossplane is the minimum Crossplane version.
+	crossplane string
+}
+
+// WriteObject records the dependencies of compositions. Other objects do
+// not add dependencies.
+func (w *configurationWriter) WriteObject(obj client.Object, _ RegisteredBuilder) error {
+	if c, ok := obj.(*xapiextv1.Composition); ok {
+		return w.Write(*c)
+	}
+	return nil
+}
+
+func (w *configurationWriter) Write(c xapiextv1.Composition) error {
+	composite := schema.FromAPIVersionAndKind(c.Spec.CompositeTypeRef.APIVersion, c.Spec.CompositeTypeRef.Kind)
+	w.composites[composite.Group] = true
+	for _, r := range c.Spec.Resources {
+		gvk, err := baseGroupVersionKind(r.Base)
+		if err != nil {
+			return errors.Wrap(err, errParseBase)
+		}
+		w.groups[gvk.Group] = true
+	}
+
+	for _, f := range crossplaneFeatures {
+		if !f.usedBy(c) {
+			continue
+		}
+		newer, err := isNewerVersion(f.version, w.crossplane)
+		if err != nil {
+			return err
+		}
+		if newer {
+			w.crossplane = f.version
+		}
+	}
+	return nil
+}
+
+// MarkPartial disables writing the Configuration, since its dependencies
+// would be incomplete.
+func (w *configurationWriter) MarkPartial() {
+	w.partial = true
+}
+
+// Finalize writes the Configuration.
+func (w *configurationWriter) Finalize() error {
+	if w.partial {
+		return nil
+	}
+	cfg, err := w.configuration()
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return errors.Wrap(err, errWriteConfiguration)
+	}
+	fsys, name := w.opts.FileSystem, w.file
+	if fsys == nil {
+		fsys, name = filesystem.NewOS(filepath.Dir(w.file)), filepath.Base(w.file)
+	}
+	return errors.Wrap(fsys.WriteFile(name, b), errWriteConfiguration)
+}
+
+func (w *configurationWriter) configuration() (*xpmetav1.Configuration, error) {
+	versions := map[string]string{}
+	unmapped := []string{}
+	for _, group := range sortedKeys(w.groups) {
+		if w.composites[group] {
+			continue
+		}
+		m, ok := w.providerFor(group)
+		if !ok {
+			unmapped = append(unmapped, group)
+			continue
+		}
+		if m.Provider == "" {
+			continue
+		}
+		if prev, exists := versions[m.Provider]; exists && prev != m.Version {
+			return nil, errors.Errorf(errFmtConflictingVersion, prev, m.Version, m.Provider)
+		}
+		versions[m.Provider] = m.Version
+	}
+	if len(unmapped) > 0 {
+		return nil, errors.Errorf(errFmtUnmappedGroups, strings.Join(unmapped, ", "))
+	}
+
+	providers := make([]string, 0, len(versions))
+	for p := range versions {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	deps := make([]xpmetav1.Dependency, len(providers))
+	for i, p := range providers {
+		deps[i] = xpmetav1.Dependency{
+			Provider: &providers[i],
+			Version:  versions[p],
+		}
+	}
+
+	crossplane := w.crossplane
+	if crossplane == "" {
+		crossplane = defaultCrossplaneVersion
+	}
+	if _, err := parseVersion(crossplane); err != nil {
+		return nil, err
+	}
+	cfg := &xpmetav1.Configuration{
+		Spec: xpmetav1.ConfigurationSpec{
+			MetaSpec: xpmetav1.MetaSpec{
+				Crossplane: &xpmetav1.CrossplaneConstraints{
+					Version: ">=" + crossplane,
+				},
+				DependsOn: deps,
+			},
+		},
+	}
+	cfg.SetGroupVersionKind(xpmetav1.ConfigurationGroupVersionKind)
+	cfg.SetName(w.opts.Name)
+	if len(w.opts.Annotations) > 0 {
+		cfg.SetAnnotations(w.opts.Annotations)
+	}
+	return cfg, nil
+}
+
+// providerFor returns the first mapping that matches group.
+func (w *configurationWriter) providerFor(group string) (ProviderMapping, bool) {
+	for _, m := range w.opts.Providers {
+		if ok, _ := path.Match(m.Group, group); ok {
+			return m, true
+		}
+	}
+	return ProviderMapping{}, false
+}
+
+// baseGroupVersionKind returns the GroupVersionKind of a composed resource
+// base.
+func baseGroupVersionKind(base runtime.RawExtension) (schema.GroupVersionKind, error) {
+	if base.Object != nil {
+		return base.Object.GetObjectKind().GroupVersionKind(), nil
+	}
+	obj := struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}{}
+	if err := json.Unmarshal(base.Raw, &obj); err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	return schema.FromAPIVersionAndKind(obj.APIVersion, obj.Kind), nil
+}
+
+// isNewerVersion returns true if version a is newer than b. An empty b is
+// older than any version.
+func isNewerVersion(a, b string) (bool, error) {
+	if b == "" {
+		return true, nil
+	}
+	pa, err := parseVersion(a)
+	if err != nil {
+		return false, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return false, err
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return pa[i] > pb[i], nil
+		}
+	}
+	return false, nil
+}
+
+// parseVersion parses a version of the form vMAJOR.MINOR.PATCH.
+func parseVersion(v string) ([3]int, error) {
+	res := [3]int{}
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(parts) != len(res) {
+		return res, errors.Errorf(errFmtInvalidVersion, v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return res, errors.Errorf(errFmtInvalidVersion, v)
+		}
+		res[i] = n
+	}
+	return res, nil
+}
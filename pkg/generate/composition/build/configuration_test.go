@@ -0,0 +1,116 @@
+package build
+
+import (
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	xpmetav1 "github.com/crossplane/crossplane/apis/pkg/meta/v1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+)
+
+func compositionWithBases(compositeAPIVersion string, baseAPIVersions ...string) xapiextv1.Composition {
+	c := xapiextv1.Composition{
+		Spec: xapiextv1.CompositionSpec{
+			CompositeTypeRef: xapiextv1.TypeReference{APIVersion: compositeAPIVersion, Kind: "XTest"},
+		},
+	}
+	for _, v := range baseAPIVersions {
+		c.Spec.Resources = append(c.Spec.Resources, xapiextv1.ComposedTemplate{
+			Base: runtime.RawExtension{Raw: []byte(`{"apiVersion":"` + v + `","kind":"Test"}`)},
+		})
+	}
+	return c
+}
+
+func TestConfigurationWriter(t *testing.T) {
+	fsys := filesystem.NewMemory()
+	w := NewConfigurationWriter(DefaultConfigurationFileName, ConfigurationOptions{
+		Name: "platform",
+		Providers: []ProviderMapping{
+			{Group: "kubernetes.crossplane.io", Provider: ""},
+			{Group: "*.aws.upbound.io", Provider: "xpkg.upbound.io/upbound/provider-aws", Version: ">=v0.40.0"},
+		},
+		FileSystem: fsys,
+	})
+
+	withEnvironment := compositionWithBases("example.org/v1alpha1", "ec2.aws.upbound.io/v1beta1")
+	withEnvironment.Spec.Environment = &xapiextv1.EnvironmentConfiguration{}
+	for _, c := range []xapiextv1.Composition{
+		compositionWithBases("example.org/v1alpha1", "s3.aws.upbound.io/v1beta1", "kubernetes.crossplane.io/v1alpha1"),
+		compositionWithBases("example.org/v1alpha1", "example.org/v1alpha1"),
+		withEnvironment,
+	} {
+		if err := w.Write(c); err != nil {
+			t.Fatalf("Write(...): %v", err)
+		}
+	}
+	if err := w.(ObjectWriter).Finalize(); err != nil {
+		t.Fatalf("Finalize(): %v", err)
+	}
+
+	b, err := fsys.ReadFile(DefaultConfigurationFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &xpmetav1.Configuration{}
+	if err := yaml.Unmarshal(b, got); err != nil {
+		t.Fatal(err)
+	}
+	provider := "xpkg.upbound.io/upbound/provider-aws"
+	want := xpmetav1.MetaSpec{
+		Crossplane: &xpmetav1.CrossplaneConstraints{Version: ">=v1.11.0"},
+		DependsOn:  []xpmetav1.Dependency{{Provider: &provider, Version: ">=v0.40.0"}},
+	}
+	if diff := cmp.Diff(want, got.Spec.MetaSpec); diff != "" {
+		t.Errorf("Finalize(): -want, +got:\n%s", diff)
+	}
+	if got.GetName() != "platform" {
+		t.Errorf("Finalize(): name: want platform, got %q", got.GetName())
+	}
+}
+
+func TestConfigurationWriterErrors(t *testing.T) {
+	cases := map[string]struct {
+		providers []ProviderMapping
+	}{
+		"UnmappedGroup": {
+			providers: []ProviderMapping{{Group: "*.gcp.upbound.io", Provider: "provider-gcp", Version: ">=v0.1.0"}},
+		},
+		"ConflictingVersions": {
+			providers: []ProviderMapping{
+				{Group: "s3.aws.upbound.io", Provider: "provider-aws", Version: ">=v0.40.0"},
+				{Group: "ec2.aws.upbound.io", Provider: "provider-aws", Version: ">=v0.41.0"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := NewConfigurationWriter(DefaultConfigurationFileName, ConfigurationOptions{
+				Providers:  tc.providers,
+				FileSystem: filesystem.NewMemory(),
+			})
+			if err := w.Write(compositionWithBases("example.org/v1alpha1", "s3.aws.upbound.io/v1beta1", "ec2.aws.upbound.io/v1beta1")); err != nil {
+				t.Fatalf("Write(...): %v", err)
+			}
+			if err := w.(ObjectWriter).Finalize(); err == nil {
+				t.Errorf("Finalize(): want error")
+			}
+		})
+	}
+}
+
+func TestConfigurationWriterPartial(t *testing.T) {
+	fsys := filesystem.NewMemory()
+	w := NewConfigurationWriter(DefaultConfigurationFileName, ConfigurationOptions{FileSystem: fsys})
+	w.(ObjectWriter).MarkPartial()
+	if err := w.(ObjectWriter).Finalize(); err != nil {
+		t.Fatalf("Finalize(): %v", err)
+	}
+	if files := fsys.Files(); len(files) > 0 {
+		t.Errorf("Finalize(): partial writer wrote %d file(s)", len(files))
+	}
+}
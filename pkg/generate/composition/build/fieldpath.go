@@ -60,18 +60,20 @@ func validatePath(obj interface{}, segments fieldpath.Segments) error {
 }
 
 func isKnownPath(path fieldpath.Segments, knownPaths []fieldpath.Segments) bool {
-	for _, known := range knownPaths {
-		if len(path) != len(known) {
-			continue
-		}
-		for i, knownSeg := range known {
-			if path[i] != knownSeg {
-				continue
-			}
+	return knownPathIndex(path, knownPaths) >= 0
+}
+
+// knownPathIndex returns the index of the known path that equals path or -1
+// if there is none. Both ValidateFieldPath and the build report use it, so
+// the report records exactly the registered paths patches were accepted
+// with.
+func knownPathIndex(path fieldpath.Segments, knownPaths []fieldpath.Segments) int {
+	for i, known := range knownPaths {
+		if reflect.DeepEqual(path, known) {
+			return i
 		}
-		return true
 	}
-	return false
+	return -1
 }
 
 func parseFieldPaths(paths []string) ([]fieldpath.Segments, error) {
@@ -0,0 +1,89 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type fieldPathTestSpec struct {
+	Region string   `json:"region"`
+	Zones  []string `json:"zones,omitempty"`
+}
+
+type fieldPathTestObject struct {
+	Spec fieldPathTestSpec `json:"spec"`
+}
+
+func TestValidateFieldPath(t *testing.T) {
+	known, err := parseFieldPaths(makeLabelPaths([]string{labelKeyClaimName}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]struct {
+		reason  string
+		obj     interface{}
+		path    string
+		wantErr bool
+	}{
+		"Field": {
+			reason: "Paths of struct fields are valid.",
+			obj:    &fieldPathTestObject{},
+			path:   "spec.region",
+		},
+		"Index": {
+			reason: "Paths of slice elements are valid.",
+			obj:    &fieldPathTestObject{},
+			path:   "spec.zones[0]",
+		},
+		"UnknownField": {
+			reason:  "Paths of fields that do not exist are invalid.",
+			obj:     &fieldPathTestObject{},
+			path:    "spec.unknown",
+			wantErr: true,
+		},
+		"Registered": {
+			reason: "Registered paths are valid.",
+			obj:    &unstructured.Unstructured{},
+			path:   "metadata.labels[crossplane.io/claim-name]",
+		},
+		"OtherLabel": {
+			reason:  "Paths that have as many segments as a registered path but differ are invalid.",
+			obj:     &unstructured.Unstructured{},
+			path:    "metadata.labels[other]",
+			wantErr: true,
+		},
+		"SameLength": {
+			reason:  "Paths must not be accepted only because a registered path has the same length.",
+			obj:     &unstructured.Unstructured{},
+			path:    "spec.forProvider.region",
+			wantErr: true,
+		},
+		"Empty": {
+			reason:  "Empty paths are invalid.",
+			obj:     &fieldPathTestObject{},
+			path:    "",
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateFieldPath(tc.obj, tc.path, known)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("\n%s\nValidateFieldPath(%q): want error %t, got %v", tc.reason, tc.path, tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKnownPathIndex(t *testing.T) {
+	known := []fieldpath.Segments{
+		{fieldpath.Field("metadata"), fieldpath.Field("labels"), fieldpath.Field("a")},
+		{fieldpath.Field("metadata"), fieldpath.Field("labels"), fieldpath.Field("b")},
+	}
+	path := fieldpath.Segments{fieldpath.Field("metadata"), fieldpath.Field("labels"), fieldpath.Field("b")}
+	if got := knownPathIndex(path, known); got != 1 {
+		t.Errorf("knownPathIndex(...): want 1, got %d", got)
+	}
+}
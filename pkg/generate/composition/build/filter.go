@@ -0,0 +1,100 @@
+package build
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	errFmtInvalidNamePattern = "invalid composition name pattern %q"
+)
+
+// CompositionNamer can be implemented by CompositionBuilders to expose the
+// name of their composition without building it. Builders must implement it
+// to be selected by the name patterns of a BuilderFilter.
+type CompositionNamer interface {
+	// GetCompositionName returns the name of the composition.
+	GetCompositionName() string
+}
+
+// BuilderFilter selects the builders a runner builds. A builder is selected
+// if it matches all non-empty criteria.
+type BuilderFilter struct {
+	// Names are glob patterns (see path.Match) of which at least one must
+	// match the composition name. If set, building fails for builders that
+	// match the other criteria but do not implement CompositionNamer.
+	Names []string
+
+	// CompositeTypes are composite kinds in the form Kind.group or
+	// Kind.version.group of which one must match the composite type of the
+	// builder.
+	CompositeTypes []string
+
+	// Tags are tags of which at least one must be set on the builder.
+	Tags []string
+}
+
+// IsEmpty returns true if this filter selects all builders.
+func (f BuilderFilter) IsEmpty() bool {
+	return len(f.Names) == 0 && len(f.CompositeTypes) == 0 && len(f.Tags) == 0
+}
+
+// Validate returns an error if any name pattern is invalid.
+func (f BuilderFilter) Validate() error {
+	for _, p := range f.Names {
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.Wrapf(err, errFmtInvalidNamePattern, p)
+		}
+	}
+	return nil
+}
+
+// MatchesBuilder returns true if the tags and composite type of the given
+// builder are selected by this filter.
+func (f BuilderFilter) MatchesBuilder(b RegisteredBuilder) bool {
+	return f.matchesTags(b) && f.matchesCompositeType(b.Builder.GetCompositeTypeRef().GroupVersionKind)
+}
+
+// MatchesName returns true if the given composition name is selected by this
+// filter.
+func (f BuilderFilter) MatchesName(name string) bool {
+	if len(f.Names) == 0 {
+		return true
+	}
+	for _, p := range f.Names {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f BuilderFilter) matchesTags(b RegisteredBuilder) bool {
+	if len(f.Tags) == 0 {
+		return true
+	}
+	for _, t := range f.Tags {
+		if b.HasTag(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f BuilderFilter) matchesCompositeType(gvk schema.GroupVersionKind) bool {
+	if len(f.CompositeTypes) == 0 {
+		return true
+	}
+	for _, t := range f.CompositeTypes {
+		fullGVK, gk := schema.ParseKindArg(t)
+		if fullGVK != nil && *fullGVK == gvk {
+			return true
+		}
+		if gk == gvk.GroupKind() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// namedBuilder is a testBuilder that implements CompositionNamer and
+// records whether it has been built.
+type namedBuilder struct {
+	testBuilder
+	built *bool
+}
+
+func (b namedBuilder) GetCompositionName() string {
+	return b.name
+}
+
+func (b namedBuilder) Build(c CompositionSkeleton) {
+	if b.built != nil {
+		*b.built = true
+	}
+	b.testBuilder.Build(c)
+}
+
+func TestBuilderFilterMatchesName(t *testing.T) {
+	cases := map[string]struct {
+		names []string
+		name  string
+		want  bool
+	}{
+		"Empty":       {names: nil, name: "anything", want: true},
+		"Exact":       {names: []string{"aws-bucket"}, name: "aws-bucket", want: true},
+		"Glob":        {names: []string{"aws-*"}, name: "aws-bucket", want: true},
+		"AnyPattern":  {names: []string{"gcp-*", "aws-?ucket"}, name: "aws-bucket", want: true},
+		"NoMatch":     {names: []string{"gcp-*"}, name: "aws-bucket", want: false},
+		"NoSeparator": {names: []string{"aws*"}, name: "aws/bucket", want: false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := BuilderFilter{Names: tc.names}
+			if got := f.MatchesName(tc.name); got != tc.want {
+				t.Errorf("MatchesName(%q) with %v: want %t, got %t", tc.name, tc.names, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBuilderFilterValidate(t *testing.T) {
+	if err := (BuilderFilter{Names: []string{"aws-["}}).Validate(); err == nil {
+		t.Errorf("Validate(): want error for invalid pattern")
+	}
+	if err := (BuilderFilter{Names: []string{"aws-[ab]*"}}).Validate(); err != nil {
+		t.Errorf("Validate(): %v", err)
+	}
+}
+
+func TestBuilderFilterMatchesBuilder(t *testing.T) {
+	rb := RegisteredBuilder{Builder: testBuilder{kind: "XBucket"}, Tags: []string{"aws", "storage"}}
+	cases := map[string]struct {
+		filter BuilderFilter
+		want   bool
+	}{
+		"Empty":               {filter: BuilderFilter{}, want: true},
+		"Tag":                 {filter: BuilderFilter{Tags: []string{"network", "aws"}}, want: true},
+		"OtherTag":            {filter: BuilderFilter{Tags: []string{"gcp"}}, want: false},
+		"KindGroup":           {filter: BuilderFilter{CompositeTypes: []string{"XBucket.example.org"}}, want: true},
+		"KindVersionGroup":    {filter: BuilderFilter{CompositeTypes: []string{"XBucket.v1alpha1.example.org"}}, want: true},
+		"OtherVersion":        {filter: BuilderFilter{CompositeTypes: []string{"XBucket.v1.example.org"}}, want: false},
+		"OtherKind":           {filter: BuilderFilter{CompositeTypes: []string{"XNetwork.example.org"}}, want: false},
+		"AllCriteria":         {filter: BuilderFilter{Tags: []string{"aws"}, CompositeTypes: []string{"XBucket.example.org"}}, want: true},
+		"AllCriteriaMismatch": {filter: BuilderFilter{Tags: []string{"gcp"}, CompositeTypes: []string{"XBucket.example.org"}}, want: false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.filter.MatchesBuilder(rb); got != tc.want {
+				t.Errorf("MatchesBuilder(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBuildFilterByName(t *testing.T) {
+	skippedBuilt, selectedBuilt := false, false
+	w := &recordingWriter{}
+	err := NewRunner(RunnerConfig{
+		Builder: []CompositionBuilder{
+			namedBuilder{testBuilder: testBuilder{name: "aws-network"}, built: &selectedBuilt},
+			namedBuilder{testBuilder: testBuilder{name: "gcp-network"}, built: &skippedBuilt},
+			testBuilder{name: "aws-bucket", kind: "XBucket"},
+		},
+		Writer: w,
+		Filter: BuilderFilter{Names: []string{"aws-*"}, CompositeTypes: []string{"XTest.example.org"}},
+	}).Build()
+	if err != nil {
+		t.Fatalf("Build(): %v", err)
+	}
+	if diff := cmp.Diff([]string{"aws-network"}, w.names); diff != "" {
+		t.Errorf("Build(): -want, +got:\n%s", diff)
+	}
+	if !selectedBuilt {
+		t.Errorf("Build(): selected CompositionNamer has not been built")
+	}
+	if skippedBuilt {
+		t.Errorf("Build(): CompositionNamer that does not match the filter has been built")
+	}
+}
+
+func TestBuildFilterByNameUnnamed(t *testing.T) {
+	w := &recordingWriter{}
+	err := NewRunner(RunnerConfig{
+		Builder: []CompositionBuilder{
+			namedBuilder{testBuilder: testBuilder{name: "aws-network"}},
+			testBuilder{name: "aws-bucket"},
+		},
+		Writer: w,
+		Filter: BuilderFilter{Names: []string{"aws-*"}},
+	}).Build()
+	if err == nil || !strings.Contains(err.Error(), "build.testBuilder") {
+		t.Errorf("Build(): want error naming build.testBuilder, got %v", err)
+	}
+	if len(w.names) > 0 {
+		t.Errorf("Build(): want nothing written, got %v", w.names)
+	}
+}
@@ -0,0 +1,75 @@
+package build
+
+import (
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+)
+
+// CompositionMutator applies common modifications to the compositions of
+// all builders of a runner.
+type CompositionMutator interface {
+	// MutateSkeleton is called after the builder has built the skeleton and
+	// before it is validated. Patches added here are validated like the
+	// ones of the builder.
+	MutateSkeleton(c MutableCompositionSkeleton) error
+
+	// MutateComposition is called with the validated composition.
+	MutateComposition(c *xapiextv1.Composition) error
+}
+
+// SkeletonMutatorFunc is a CompositionMutator that only mutates the
+// skeleton.
+type SkeletonMutatorFunc func(c MutableCompositionSkeleton) error
+
+// MutateSkeleton calls the function.
+func (fn SkeletonMutatorFunc) MutateSkeleton(c MutableCompositionSkeleton) error {
+	return fn(c)
+}
+
+// MutateComposition does nothing.
+func (fn SkeletonMutatorFunc) MutateComposition(_ *xapiextv1.Composition) error {
+	return nil
+}
+
+// CompositionMutatorFunc is a CompositionMutator that only mutates the final
+// composition.
+type CompositionMutatorFunc func(c *xapiextv1.Composition) error
+
+// MutateSkeleton does nothing.
+func (fn CompositionMutatorFunc) MutateSkeleton(_ MutableCompositionSkeleton) error {
+	return nil
+}
+
+// MutateComposition calls the function.
+func (fn CompositionMutatorFunc) MutateComposition(c *xapiextv1.Composition) error {
+	return fn(c)
+}
+
+// WithCompositionLabels returns a mutator that adds the given labels to
+// every composition. Existing labels are overwritten.
+func WithCompositionLabels(labels map[string]string) CompositionMutator {
+	return CompositionMutatorFunc(func(c *xapiextv1.Composition) error {
+		merged := c.GetLabels()
+		if merged == nil {
+			merged = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			merged[k] = v
+		}
+		c.SetLabels(merged)
+		return nil
+	})
+}
+
+// WithResourcePatches returns a mutator that adds the given patches to every
+// composed resource for which filter returns true. If filter is nil, the
+// patches are added to all resources.
+func WithResourcePatches(filter func(r MutableComposedTemplateSkeleton) bool, patches ...xapiextv1.Patch) CompositionMutator {
+	return SkeletonMutatorFunc(func(c MutableCompositionSkeleton) error {
+		for _, r := range c.Resources() {
+			if filter == nil || filter(r) {
+				r.WithPatches(patches...)
+			}
+		}
+		return nil
+	})
+}
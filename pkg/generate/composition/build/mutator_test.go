@@ -0,0 +1,74 @@
+package build
+
+import (
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+)
+
+func TestWithResourcePatches(t *testing.T) {
+	bucketKind := schema.GroupVersionKind{Group: "s3.aws.upbound.io", Version: "v1beta1", Kind: "Bucket"}
+	roleKind := schema.GroupVersionKind{Group: "iam.aws.upbound.io", Version: "v1beta1", Kind: "Role"}
+
+	c := &compositionSkeleton{composite: testBuilder{}.GetCompositeTypeRef()}
+	c.NewResource(ObjectKindReference{GroupVersionKind: bucketKind, Object: &unstructured.Unstructured{}})
+	c.NewResource(ObjectKindReference{GroupVersionKind: roleKind, Object: &unstructured.Unstructured{}})
+
+	patch := xapiextv1.Patch{
+		Type:          xapiextv1.PatchTypeFromCompositeFieldPath,
+		FromFieldPath: ptr.To("spec.region"),
+		ToFieldPath:   ptr.To("spec.forProvider.region"),
+	}
+	onlyBuckets := func(r MutableComposedTemplateSkeleton) bool {
+		return r.GetBase().GroupVersionKind == bucketKind
+	}
+	if err := WithResourcePatches(onlyBuckets, patch).MutateSkeleton(c); err != nil {
+		t.Fatalf("MutateSkeleton(...): %v", err)
+	}
+
+	got := make([]int, len(c.composeTemplateSkeletons))
+	for i, r := range c.composeTemplateSkeletons {
+		got[i] = len(r.patches)
+	}
+	if diff := cmp.Diff([]int{1, 0}, got); diff != "" {
+		t.Errorf("MutateSkeleton(...): patches per resource: -want, +got:\n%s", diff)
+	}
+}
+
+func TestWithCompositionLabels(t *testing.T) {
+	comp := &xapiextv1.Composition{}
+	comp.SetLabels(map[string]string{"a": "old", "b": "kept"})
+
+	err := WithCompositionLabels(map[string]string{"a": "new", "c": "added"}).MutateComposition(comp)
+	if err != nil {
+		t.Fatalf("MutateComposition(...): %v", err)
+	}
+	want := map[string]string{"a": "new", "b": "kept", "c": "added"}
+	if diff := cmp.Diff(want, comp.GetLabels()); diff != "" {
+		t.Errorf("MutateComposition(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestBuildMutators(t *testing.T) {
+	w := &recordingWriter{}
+	err := NewRunner(RunnerConfig{
+		Builder: []CompositionBuilder{testBuilder{name: "a"}},
+		Writer:  w,
+		Mutators: []CompositionMutator{
+			SkeletonMutatorFunc(func(c MutableCompositionSkeleton) error {
+				c.WithName(c.GetName() + "-mutated")
+				return nil
+			}),
+		},
+	}).Build()
+	if err != nil {
+		t.Fatalf("Build(): %v", err)
+	}
+	if diff := cmp.Diff([]string{"a-mutated"}, w.names); diff != "" {
+		t.Errorf("Build(): -want, +got:\n%s", diff)
+	}
+}
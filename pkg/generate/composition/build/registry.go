@@ -0,0 +1,124 @@
+package build
+
+import (
+	"reflect"
+	"sync"
+)
+
+// DefaultRegistry is the registry builders add themselves to using Register.
+var DefaultRegistry = NewRegistry()
+
+// Register adds the given builder to the DefaultRegistry. It is intended to
+// be called from the init() function of the package that declares the
+// builder.
+func Register(builder CompositionBuilder, opts ...RegisterOption) {
+	DefaultRegistry.Register(builder, opts...)
+}
+
+// RegisteredBuilder is a CompositionBuilder together with the metadata it was
+// registered with.
+type RegisteredBuilder struct {
+	// Builder is the registered builder.
+	Builder CompositionBuilder
+
+	// Group is the group the builder belongs to. Empty if the builder was
+	// registered without a group.
+	Group string
+
+	// Tags are arbitrary tags that have been attached to the builder.
+	Tags []string
+}
+
+// HasTag returns true if the builder has been registered with the given tag.
+func (r RegisteredBuilder) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterOption configures a builder registration.
+type RegisterOption func(r *RegisteredBuilder)
+
+// WithGroup assigns the builder to the given group.
+func WithGroup(group string) RegisterOption {
+	return func(r *RegisteredBuilder) {
+		r.Group = group
+	}
+}
+
+// WithTags attaches the given tags to the builder.
+func WithTags(tags ...string) RegisterOption {
+	return func(r *RegisteredBuilder) {
+		r.Tags = append(r.Tags, tags...)
+	}
+}
+
+// Registry holds a list of CompositionBuilders in the order they have been
+// registered. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	entries []RegisteredBuilder
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the given builder to this registry.
+func (r *Registry) Register(builder CompositionBuilder, opts ...RegisterOption) {
+	entry := RegisteredBuilder{
+		Builder: builder,
+	}
+	for _, o := range opts {
+		o(&entry)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// Entries returns all registrations in the order they have been made.
+func (r *Registry) Entries() []RegisteredBuilder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := make([]RegisteredBuilder, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// ContainsType returns true if a builder of the same type as the given one
+// has been registered.
+func (r *Registry) ContainsType(builder CompositionBuilder) bool {
+	t := reflect.TypeOf(builder)
+	return len(r.filter(func(e RegisteredBuilder) bool { return reflect.TypeOf(e.Builder) == t })) > 0
+}
+
+// Builders returns all registered builders.
+func (r *Registry) Builders() []CompositionBuilder {
+	return r.filter(func(RegisteredBuilder) bool { return true })
+}
+
+// Group returns all builders that have been registered with the given group.
+func (r *Registry) Group(group string) []CompositionBuilder {
+	return r.filter(func(e RegisteredBuilder) bool { return e.Group == group })
+}
+
+// Tagged returns all builders that have been registered with the given tag.
+func (r *Registry) Tagged(tag string) []CompositionBuilder {
+	return r.filter(func(e RegisteredBuilder) bool { return e.HasTag(tag) })
+}
+
+func (r *Registry) filter(fn func(e RegisteredBuilder) bool) []CompositionBuilder {
+	builders := []CompositionBuilder{}
+	for _, e := range r.Entries() {
+		if fn(e) {
+			builders = append(builders, e.Builder)
+		}
+	}
+	return builders
+}
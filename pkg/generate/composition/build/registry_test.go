@@ -0,0 +1,93 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// otherBuilder is a builder of a different type than testBuilder.
+type otherBuilder struct {
+	testBuilder
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register(testBuilder{name: "a"}, WithGroup("aws"), WithTags("network"))
+	r.Register(testBuilder{name: "b"}, WithTags("network", "database"))
+	r.Register(otherBuilder{testBuilder{name: "c"}}, WithGroup("aws"))
+
+	cases := map[string]struct {
+		got  []CompositionBuilder
+		want []string
+	}{
+		"Builders": {
+			got:  r.Builders(),
+			want: []string{"a", "b", "c"},
+		},
+		"Group": {
+			got:  r.Group("aws"),
+			want: []string{"a", "c"},
+		},
+		"Tagged": {
+			got:  r.Tagged("network"),
+			want: []string{"a", "b"},
+		},
+		"UnknownTag": {
+			got:  r.Tagged("unknown"),
+			want: []string{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, builderNames(tc.got)); diff != "" {
+				t.Errorf("-want, +got:\n%s", diff)
+			}
+		})
+	}
+
+	if !r.ContainsType(testBuilder{}) {
+		t.Errorf("ContainsType(testBuilder{}): want true")
+	}
+	if r.ContainsType(failingBuilder{}) {
+		t.Errorf("ContainsType(failingBuilder{}): want false")
+	}
+}
+
+func TestRunnerBuilders(t *testing.T) {
+	r := NewRegistry()
+	r.Register(testBuilder{name: "registered"})
+	r.Register(otherBuilder{testBuilder{name: "other"}})
+
+	runner := &compositionBuildRunner{
+		config: RunnerConfig{
+			Builder:  []CompositionBuilder{testBuilder{name: "explicit"}},
+			Registry: r,
+		},
+	}
+	builders, err := runner.builders()
+	if err != nil {
+		t.Fatalf("builders(): %v", err)
+	}
+	got := []CompositionBuilder{}
+	for _, rb := range builders {
+		got = append(got, rb.Builder)
+	}
+	want := []string{"explicit", "other"}
+	if diff := cmp.Diff(want, builderNames(got)); diff != "" {
+		t.Errorf("builders(): -want, +got:\n%s", diff)
+	}
+}
+
+func builderNames(builders []CompositionBuilder) []string {
+	names := make([]string, len(builders))
+	for i, b := range builders {
+		switch b := b.(type) {
+		case testBuilder:
+			names[i] = b.name
+		case otherBuilder:
+			names[i] = b.name
+		}
+	}
+	return names
+}
@@ -0,0 +1,240 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/utils"
+)
+
+const (
+	errWriteReport = "failed to write build report"
+)
+
+// Report is a machine-readable summary of a build.
+type Report struct {
+	// Compositions contains a report for each composition that has been
+	// built, in the order they have been written.
+	Compositions []CompositionReport `json:"compositions"`
+}
+
+// CompositionReport summarizes a single composition.
+type CompositionReport struct {
+	// Name is the name of the composition.
+	Name string `json:"name"`
+
+	// Builder is the type name of the builder.
+	Builder string `json:"builder"`
+
+	// CompositeType is the composite type of the composition.
+	CompositeType TypeReport `json:"compositeType"`
+
+	// Resources are the composed templates of the composition.
+	Resources []ResourceReport `json:"resources"`
+
+	// RegisteredCompositePaths are the registered composite paths that
+	// have been used to validate patches.
+	RegisteredCompositePaths []string `json:"registeredCompositePaths,omitempty"`
+}
+
+// TypeReport identifies a Kubernetes type.
+type TypeReport struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// ResourceReport summarizes a composed template.
+type ResourceReport struct {
+	// Name is the name of the composed template, if set.
+	Name *string `json:"name,omitempty"`
+
+	// Type is the type of the base resource.
+	Type TypeReport `json:"type"`
+
+	// PatchCounts is the number of patches by patch type.
+	PatchCounts map[string]int `json:"patchCounts,omitempty"`
+
+	// UnsafePatches are the patches whose field paths have not been
+	// validated.
+	UnsafePatches []UnsafePatchReport `json:"unsafePatches,omitempty"`
+
+	// RegisteredPaths are the registered resource paths that have been used
+	// to validate patches.
+	RegisteredPaths []string `json:"registeredPaths,omitempty"`
+}
+
+// UnsafePatchReport describes a patch that has been added with
+// WithUnsafePatches.
+type UnsafePatchReport struct {
+	// Index is the index of the patch in the composed template.
+	Index int `json:"index"`
+
+	// Type is the type of the patch.
+	Type string `json:"type"`
+
+	// FromFieldPath is the fromFieldPath of the patch, if set.
+	FromFieldPath string `json:"fromFieldPath,omitempty"`
+
+	// ToFieldPath is the toFieldPath of the patch, if set.
+	ToFieldPath string `json:"toFieldPath,omitempty"`
+
+	// Source is the location WithUnsafePatches has been called from.
+	Source string `json:"source"`
+}
+
+// Write writes this report as indented JSON to w.
+func (r *Report) Write(w io.Writer) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, errWriteReport)
+	}
+	_, err = w.Write(append(b, '\n'))
+	return errors.Wrap(err, errWriteReport)
+}
+
+// report creates a report for this skeleton. It must be called after
+// ToComposition.
+func (c *compositionSkeleton) report(builder string) CompositionReport {
+	gvk := c.composite.GroupVersionKind
+	res := CompositionReport{
+		Name:    c.name,
+		Builder: builder,
+		CompositeType: TypeReport{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+		},
+		Resources: make([]ResourceReport, len(c.composeTemplateSkeletons)),
+	}
+	compositePaths := map[string]bool{}
+	for i, r := range c.composeTemplateSkeletons {
+		res.Resources[i] = r.report()
+		for p := range r.usedCompositePaths {
+			compositePaths[p] = true
+		}
+	}
+	res.RegisteredCompositePaths = sortedKeys(compositePaths)
+	return res
+}
+
+func (c *composeTemplateSkeleton) report() ResourceReport {
+	gvk := c.base.GroupVersionKind
+	res := ResourceReport{
+		Name: c.name,
+		Type: TypeReport{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+		},
+		RegisteredPaths: sortedKeys(c.usedPaths),
+	}
+	for i, p := range c.patches {
+		patchType := string(patchTypeOf(p.patch))
+		if res.PatchCounts == nil {
+			res.PatchCounts = map[string]int{}
+		}
+		res.PatchCounts[patchType]++
+		if p.unsafe {
+			res.UnsafePatches = append(res.UnsafePatches, UnsafePatchReport{
+				Index:         i,
+				Type:          patchType,
+				FromFieldPath: utils.StringValue(p.patch.FromFieldPath),
+				ToFieldPath:   utils.StringValue(p.patch.ToFieldPath),
+				Source:        p.source,
+			})
+		}
+	}
+	return res
+}
+
+// recordUsedPaths records the registered paths that the field paths of the
+// given patch have been validated with.
+func (c *composeTemplateSkeleton) recordUsedPaths(patch xapiextv1.Patch, registeredCompositePaths, registeredPaths []fieldpath.Segments) {
+	compositeFieldPaths, composedFieldPaths := patchFieldPaths(patch)
+	if c.usedCompositePaths == nil {
+		c.usedCompositePaths = map[string]bool{}
+	}
+	if c.usedPaths == nil {
+		c.usedPaths = map[string]bool{}
+	}
+	recordUsed(c.usedCompositePaths, compositeFieldPaths, registeredCompositePaths, c.compositionSkeleton.registeredPaths)
+	recordUsed(c.usedPaths, composedFieldPaths, registeredPaths, c.registeredPaths)
+}
+
+// recordUsed adds the registered paths that match any of the given field
+// paths to used. names contains the unparsed registered paths.
+func recordUsed(used map[string]bool, fieldPaths []string, registered []fieldpath.Segments, names []string) {
+	for _, fp := range fieldPaths {
+		segments, err := fieldpath.Parse(fp)
+		if err != nil {
+			continue
+		}
+		if i := knownPathIndex(segments, registered); i >= 0 {
+			used[names[i]] = true
+		}
+	}
+}
+
+// patchFieldPaths returns the field paths of the given patch that refer to
+// the composite and to the composed resource.
+func patchFieldPaths(patch xapiextv1.Patch) (composite, composed []string) {
+	from := []string{utils.StringValue(patch.FromFieldPath)}
+	if patch.Combine != nil {
+		from = make([]string, len(patch.Combine.Variables))
+		for i, v := range patch.Combine.Variables {
+			from[i] = v.FromFieldPath
+		}
+	}
+	to := []string{utils.StringValue(patch.ToFieldPath)}
+
+	switch patchTypeOf(patch) {
+	case xapiextv1.PatchTypeFromCompositeFieldPath, xapiextv1.PatchTypeCombineFromComposite:
+		return from, to
+	case xapiextv1.PatchTypeToCompositeFieldPath, xapiextv1.PatchTypeCombineToComposite:
+		return to, from
+	}
+	return nil, nil
+}
+
+// patchTypeOf returns the type of the given patch, taking the default into
+// account.
+func patchTypeOf(patch xapiextv1.Patch) xapiextv1.PatchType {
+	if patch.Type == "" {
+		return xapiextv1.PatchTypeFromCompositeFieldPath
+	}
+	return patch.Type
+}
+
+// callerLocation returns the file and line of the caller skip levels up the
+// stack. The file is relative to the working directory if possible.
+func callerLocation(skip int) string {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, file); err == nil {
+			file = filepath.ToSlash(rel)
+		}
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,130 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+)
+
+// reportBuilder builds a composition with a validated and an unsafe patch.
+type reportBuilder struct{}
+
+func (reportBuilder) GetCompositeTypeRef() ObjectKindReference {
+	return testBuilder{}.GetCompositeTypeRef()
+}
+
+func (reportBuilder) Build(c CompositionSkeleton) {
+	c.WithName("report")
+	c.NewResource(ObjectKindReference{
+		GroupVersionKind: schema.GroupVersionKind{Group: "s3.aws.upbound.io", Version: "v1beta1", Kind: "Bucket"},
+		Object:           &unstructured.Unstructured{},
+	}).
+		WithName("bucket").
+		RegisterAnnotations(meta.AnnotationKeyExternalName).
+		WithPatches(xapiextv1.Patch{
+			Type:          xapiextv1.PatchTypeFromCompositeFieldPath,
+			FromFieldPath: ptr.To("metadata.labels[" + labelKeyClaimName + "]"),
+			ToFieldPath:   ptr.To("metadata.annotations[" + meta.AnnotationKeyExternalName + "]"),
+		}).
+		WithUnsafePatches(xapiextv1.Patch{
+			Type:          xapiextv1.PatchTypeFromCompositeFieldPath,
+			FromFieldPath: ptr.To("spec.region"),
+			ToFieldPath:   ptr.To("spec.forProvider.region"),
+		})
+}
+
+func TestBuildReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := NewRunner(RunnerConfig{
+		Builder: []CompositionBuilder{reportBuilder{}},
+		Writer:  &recordingWriter{},
+		Report:  buf,
+	}).Build()
+	if err != nil {
+		t.Fatalf("Build(): %v", err)
+	}
+
+	got := &Report{}
+	if err := json.Unmarshal(buf.Bytes(), got); err != nil {
+		t.Fatalf("Build(): invalid report: %v", err)
+	}
+	want := &Report{Compositions: []CompositionReport{{
+		Name:    "report",
+		Builder: "build.reportBuilder",
+		CompositeType: TypeReport{
+			APIVersion: "example.org/v1alpha1",
+			Kind:       "XTest",
+		},
+		Resources: []ResourceReport{{
+			Name:        ptr.To("bucket"),
+			Type:        TypeReport{APIVersion: "s3.aws.upbound.io/v1beta1", Kind: "Bucket"},
+			PatchCounts: map[string]int{string(xapiextv1.PatchTypeFromCompositeFieldPath): 2},
+			UnsafePatches: []UnsafePatchReport{{
+				Index:         1,
+				Type:          string(xapiextv1.PatchTypeFromCompositeFieldPath),
+				FromFieldPath: "spec.region",
+				ToFieldPath:   "spec.forProvider.region",
+			}},
+			RegisteredPaths: []string{"metadata.annotations[" + meta.AnnotationKeyExternalName + "]"},
+		}},
+		RegisteredCompositePaths: []string{"metadata.labels[" + labelKeyClaimName + "]"},
+	}}}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(UnsafePatchReport{}, "Source")); diff != "" {
+		t.Errorf("Build(): report: -want, +got:\n%s", diff)
+	}
+	if src := got.Compositions[0].Resources[0].UnsafePatches[0].Source; !bytes.Contains([]byte(src), []byte("report_test.go")) {
+		t.Errorf("Build(): unsafe patch source: want report_test.go, got %q", src)
+	}
+}
+
+func TestRecordUsed(t *testing.T) {
+	names := makeLabelPaths([]string{labelKeyClaimName})
+	registered, err := parseFieldPaths(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]struct {
+		reason string
+		path   string
+		want   []string
+	}{
+		"Registered": {
+			reason: "Field paths that equal a registered path are recorded.",
+			path:   "metadata.labels[" + labelKeyClaimName + "]",
+			want:   names,
+		},
+		"OtherLabel": {
+			reason: "Field paths that differ from a registered path in the last segment are not recorded.",
+			path:   "metadata.labels[other]",
+		},
+		"SameLength": {
+			reason: "Field paths are not recorded only because a registered path has the same number of segments.",
+			path:   "spec.forProvider.region",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			used := map[string]bool{}
+			recordUsed(used, []string{tc.path}, registered, names)
+			if diff := cmp.Diff(tc.want, sortedKeys(used)); diff != "" {
+				t.Errorf("\n%s\nrecordUsed(...): -want, +got:\n%s", tc.reason, diff)
+			}
+
+			// Unstructured objects have no fields to validate against, so
+			// ValidateFieldPath only accepts registered paths and must agree
+			// with the report.
+			valid := ValidateFieldPath(&unstructured.Unstructured{}, tc.path, registered) == nil
+			if recorded := len(used) > 0; valid != recorded {
+				t.Errorf("\n%s\nValidateFieldPath(%q) accepted: %t, recordUsed(...) recorded: %t", tc.reason, tc.path, valid, recorded)
+			}
+		})
+	}
+}
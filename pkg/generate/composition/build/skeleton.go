@@ -115,6 +115,46 @@ type CompositionSkeleton interface {
 	RegisterCompositeFieldPaths(paths ...string) CompositionSkeleton
 }
 
+// ComposedTemplateSkeletonReader provides read access to the state of a
+// ComposedTemplateSkeleton.
+type ComposedTemplateSkeletonReader interface {
+	// GetName returns the name of the composed template or nil if none has
+	// been set.
+	GetName() *string
+
+	// GetBase returns the base of the composed template.
+	GetBase() ObjectKindReference
+}
+
+// CompositionSkeletonReader provides read access to the state of a
+// CompositionSkeleton.
+type CompositionSkeletonReader interface {
+	// GetName returns the metadata.name of the composition to be built.
+	GetName() string
+
+	// GetCompositeTypeRef returns the composite type of the composition to
+	// be built.
+	GetCompositeTypeRef() ObjectKindReference
+}
+
+// MutableComposedTemplateSkeleton is a ComposedTemplateSkeleton whose state
+// can be read.
+type MutableComposedTemplateSkeleton interface {
+	ComposedTemplateSkeleton
+	ComposedTemplateSkeletonReader
+}
+
+// MutableCompositionSkeleton is a CompositionSkeleton whose state can be
+// read. It is passed to CompositionMutators.
+type MutableCompositionSkeleton interface {
+	CompositionSkeleton
+	CompositionSkeletonReader
+
+	// Resources returns all composed templates that have been created so
+	// far.
+	Resources() []MutableComposedTemplateSkeleton
+}
+
 // Object is an extension of the k8s runtime.Object with additional functions
 // that are required by Crossbuildec.
 type Object interface {
@@ -169,6 +209,27 @@ func (c *compositionSkeleton) RegisterCompositeFieldPaths(path ...string) Compos
 	return c
 }
 
+// GetName returns the metadata.name of the composition to be built.
+func (c *compositionSkeleton) GetName() string {
+	return c.name
+}
+
+// GetCompositeTypeRef returns the composite type of the composition to be
+// built.
+func (c *compositionSkeleton) GetCompositeTypeRef() ObjectKindReference {
+	return c.composite
+}
+
+// Resources returns all composeTemplateSkeletons that have been created so
+// far.
+func (c *compositionSkeleton) Resources() []MutableComposedTemplateSkeleton {
+	res := make([]MutableComposedTemplateSkeleton, len(c.composeTemplateSkeletons))
+	for i, r := range c.composeTemplateSkeletons {
+		res[i] = r
+	}
+	return res
+}
+
 // WithName sets the metadata.name of the composition to be built.
 func (c *compositionSkeleton) WithName(name string) CompositionSkeleton {
 	c.name = name
@@ -234,6 +295,9 @@ func (c *compositionSkeleton) ToComposition() (xapiextv1.Composition, error) {
 type patchSkeleton struct {
 	patch  xapiextv1.Patch
 	unsafe bool
+
+	// source is the location WithUnsafePatches has been called from.
+	source string
 }
 
 type composeTemplateSkeleton struct {
@@ -245,6 +309,11 @@ type composeTemplateSkeleton struct {
 	patches           []patchSkeleton
 	connectionDetails []xapiextv1.ConnectionDetail
 	readinessChecks   []xapiextv1.ReadinessCheck
+
+	// usedCompositePaths and usedPaths are the registered paths that
+	// have been used to validate patches.
+	usedCompositePaths map[string]bool
+	usedPaths          map[string]bool
 }
 
 // RegisterAnnotations marks the given resource annotations as safe
@@ -266,6 +335,17 @@ func (c *composeTemplateSkeleton) RegisterFieldPaths(paths ...string) ComposedTe
 	return c
 }
 
+// GetName returns the name of this composeTemplateSkeleton or nil if none
+// has been set.
+func (c *composeTemplateSkeleton) GetName() *string {
+	return c.name
+}
+
+// GetBase returns the base of this composeTemplateSkeleton.
+func (c *composeTemplateSkeleton) GetBase() ObjectKindReference {
+	return c.base
+}
+
 // WithName sets the name of this composeTemplateSkeleton.
 func (c *composeTemplateSkeleton) WithName(name string) ComposedTemplateSkeleton {
 	c.name = &name
@@ -286,10 +366,12 @@ func (c *composeTemplateSkeleton) WithPatches(patches ...xapiextv1.Patch) Compos
 // WithUnsafePatches is similar to WithPatches but the field paths of the
 // composeTemplateSkeletons will not be validated.
 func (c *composeTemplateSkeleton) WithUnsafePatches(patches ...xapiextv1.Patch) ComposedTemplateSkeleton {
+	source := callerLocation(2)
 	for _, patch := range patches {
 		c.patches = append(c.patches, patchSkeleton{
 			patch:  patch,
 			unsafe: true,
+			source: source,
 		})
 	}
 	return c
@@ -328,6 +410,7 @@ func (c *composeTemplateSkeleton) ToComposedTemplate() (xapiextv1.ComposedTempla
 			if err := c.validatePatch(p.patch, registeredCompositePaths, registeredPaths); err != nil {
 				return xapiextv1.ComposedTemplate{}, errors.Wrapf(err, errFmtInvalidPatch, i)
 			}
+			c.recordUsedPaths(p.patch, registeredCompositePaths, registeredPaths)
 		}
 		patches[i] = p.patch
 	}
@@ -347,11 +430,7 @@ func (c *composeTemplateSkeleton) ToComposedTemplate() (xapiextv1.ComposedTempla
 }
 
 func (c *composeTemplateSkeleton) validatePatch(patch xapiextv1.Patch, registeredCompositePaths, registeredPaths []fieldpath.Segments) error {
-	patchType := patch.Type
-	if patchType == "" {
-		patchType = xapiextv1.PatchTypeFromCompositeFieldPath
-	}
-
+	patchType := patchTypeOf(patch)
 	switch patchType {
 	case xapiextv1.PatchTypeFromCompositeFieldPath:
 		return validatePatch(patch, c.compositionSkeleton.composite.Object, c.base.Object, registeredCompositePaths, registeredPaths)
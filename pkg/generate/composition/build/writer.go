@@ -1,15 +1,37 @@
 package build
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
-	"io/ioutil"
-	"os"
 	"path/filepath"
+	"strings"
 
 	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/diff"
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+	"github.com/mistermx/crossbuilder/pkg/generate/index"
+	"github.com/mistermx/crossbuilder/pkg/generate/kustomize"
+	"github.com/mistermx/crossbuilder/pkg/generate/layout"
+	"github.com/mistermx/crossbuilder/pkg/generate/provenance"
+	"github.com/mistermx/crossbuilder/pkg/generate/verify"
+)
+
+const (
+	errFmtUnknownWriterFormat = "unknown writer format %q"
+	errFmtDuplicateFile       = "file %s of %s %s has already been written"
+	errFmtUnsupportedObject   = "writer does not support objects of kind %s"
+	errFmtMissingKind         = "%s has no apiVersion or kind"
+
+	yamlDocumentSeparator = "---\n"
 )
 
 // CompositionWriter specifies the interface for a delegate that writes the
@@ -19,20 +41,201 @@ type CompositionWriter interface {
 	Write(c xapiextv1.Composition) error
 }
 
+// ObjectWriter is a CompositionWriter that writes arbitrary objects, e.g.
+// EnvironmentConfigs, example claims, Usages or XRDs, and takes part in the
+// lifecycle of a run. All writers of this package implement it. Custom
+// writers can embed BaseWriter and only implement the methods they need.
+type ObjectWriter interface {
+	CompositionWriter
+
+	// WriteObject is called by the runner instead of Write for every built
+	// object. The apiVersion and kind of the object must be set. The
+	// builder is the registration of the builder the object has been built
+	// by and empty if it is unknown.
+	WriteObject(obj client.Object, builder RegisteredBuilder) error
+
+	// MarkPartial is called by the runner before writing if only a subset
+	// of all compositions is written, i.e. because a filter is used.
+	// Compositions that are not written must then not be treated as
+	// deleted.
+	MarkPartial()
+
+	// Finalize is called by the runner after all objects have been written
+	// successfully.
+	Finalize() error
+}
+
+// BaseWriter implements MarkPartial and Finalize of ObjectWriter as no-ops.
+type BaseWriter struct{}
+
+// MarkPartial does nothing.
+func (BaseWriter) MarkPartial() {}
+
+// Finalize does nothing.
+func (BaseWriter) Finalize() error { return nil }
+
+// NewMultiWriter creates a CompositionWriter that writes each composition
+// to all of the given writers in order. WriteObject, MarkPartial and
+// Finalize are passed on to the writers that implement ObjectWriter.
+func NewMultiWriter(writers ...CompositionWriter) CompositionWriter {
+	return &multiWriter{
+		writers: writers,
+	}
+}
+
+type multiWriter struct {
+	writers []CompositionWriter
+}
+
+func (w *multiWriter) Write(c xapiextv1.Composition) error {
+	return w.WriteObject(&c, RegisteredBuilder{})
+}
+
+// WriteObject writes the object to all writers.
+func (w *multiWriter) WriteObject(obj client.Object, builder RegisteredBuilder) error {
+	for _, cw := range w.writers {
+		if err := writeObject(cw, obj, builder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeObject writes obj using WriteObject if w implements ObjectWriter.
+// Other writers can only write compositions.
+func writeObject(w CompositionWriter, obj client.Object, builder RegisteredBuilder) error {
+	if ow, ok := w.(ObjectWriter); ok {
+		return ow.WriteObject(obj, builder)
+	}
+	if c, ok := obj.(*xapiextv1.Composition); ok {
+		return w.Write(*c)
+	}
+	return errors.Errorf(errFmtUnsupportedObject, obj.GetObjectKind().GroupVersionKind().Kind)
+}
+
+// MarkPartial marks all writers that implement ObjectWriter as partial.
+func (w *multiWriter) MarkPartial() {
+	for _, cw := range w.writers {
+		if ow, ok := cw.(ObjectWriter); ok {
+			ow.MarkPartial()
+		}
+	}
+}
+
+// Finalize finalizes all writers that implement ObjectWriter.
+func (w *multiWriter) Finalize() error {
+	for _, cw := range w.writers {
+		if ow, ok := cw.(ObjectWriter); ok {
+			if err := ow.Finalize(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriterFormat is the format NewWriterWriter writes compositions in.
+type WriterFormat string
+
+// Supported writer formats.
+const (
+	// WriterFormatYAML writes a multi-document YAML stream.
+	WriterFormatYAML WriterFormat = "yaml"
+
+	// WriterFormatJSON writes one indented JSON document per composition.
+	WriterFormatJSON WriterFormat = "json"
+
+	// WriterFormatJSONLines writes one JSON document per line.
+	WriterFormatJSONLines WriterFormat = "jsonl"
+
+	// WriterFormatList writes a single YAML v1.List that contains all
+	// compositions.
+	WriterFormatList WriterFormat = "list"
+)
+
+// Validate returns an error if f is not a supported format.
+func (f WriterFormat) Validate() error {
+	switch f {
+	case WriterFormatYAML, WriterFormatJSON, WriterFormatJSONLines, WriterFormatList:
+		return nil
+	}
+	return errors.Errorf(errFmtUnknownWriterFormat, f)
+}
+
+// WriterOption configures a writer created by NewWriterWriter.
+type WriterOption func(w *writerWriter)
+
+// WithWriterFormat sets the format compositions are written in. Defaults to
+// WriterFormatYAML.
+func WithWriterFormat(f WriterFormat) WriterOption {
+	return func(w *writerWriter) {
+		w.format = f
+	}
+}
+
+// WithWriterHeader writes text as YAML comment before the first
+// composition. It is ignored by the JSON formats.
+func WithWriterHeader(text string) WriterOption {
+	return func(w *writerWriter) {
+		w.header = provenance.Header(text)
+	}
+}
+
 // NewWriterWriter creates a CompositionWriter that writes to the given
-// io.Writer.
-func NewWriterWriter(w io.Writer) CompositionWriter {
-	return &writerWriter{
+// io.Writer. It returns an error if the format is not supported.
+func NewWriterWriter(w io.Writer, opts ...WriterOption) (CompositionWriter, error) {
+	ww := &writerWriter{
 		writer: w,
+		format: WriterFormatYAML,
+	}
+	for _, o := range opts {
+		o(ww)
 	}
+	if err := ww.format.Validate(); err != nil {
+		return nil, err
+	}
+	return ww, nil
 }
 
 type writerWriter struct {
+	BaseWriter
+
 	writer io.Writer
+	format WriterFormat
+	header string
+
+	// items are the compositions collected for WriterFormatList.
+	items []runtime.RawExtension
 }
 
 func (w *writerWriter) Write(c xapiextv1.Composition) error {
-	b, err := yaml.Marshal(c)
+	return w.WriteObject(&c, RegisteredBuilder{})
+}
+
+// WriteObject writes the object in the configured format.
+func (w *writerWriter) WriteObject(obj client.Object, _ RegisteredBuilder) error {
+	var (
+		b   []byte
+		err error
+	)
+	switch w.format {
+	case WriterFormatYAML:
+		b, err = yaml.Marshal(obj)
+		b = append([]byte(w.takeHeader()+yamlDocumentSeparator), b...)
+	case WriterFormatJSON:
+		b, err = json.MarshalIndent(obj, "", "  ")
+		b = append(b, '\n')
+	case WriterFormatJSONLines:
+		b, err = json.Marshal(obj)
+		b = append(b, '\n')
+	case WriterFormatList:
+		b, err = json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		w.items = append(w.items, runtime.RawExtension{Raw: b})
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -40,27 +243,365 @@ func (w *writerWriter) Write(c xapiextv1.Composition) error {
 	return err
 }
 
+// Finalize writes the list of all compositions if WriterFormatList is
+// used.
+func (w *writerWriter) Finalize() error {
+	if w.format != WriterFormatList {
+		return nil
+	}
+	list := &metav1.List{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "List",
+		},
+		Items: w.items,
+	}
+	if list.Items == nil {
+		list.Items = []runtime.RawExtension{}
+	}
+	b, err := yaml.Marshal(list)
+	if err != nil {
+		return err
+	}
+	_, err = w.writer.Write(append([]byte(w.takeHeader()), b...))
+	return err
+}
+
+// takeHeader returns the header if it has not been written yet.
+func (w *writerWriter) takeHeader() string {
+	h := w.header
+	w.header = ""
+	return h
+}
+
+// DirectoryOption configures a directory that compositions are written to
+// or compared with.
+type DirectoryOption func(l *fileLayout)
+
+// WithFileSystem makes the writer use the directory of fsys at the given
+// dir instead of the local file system. This allows writing atomically
+// with custom modes or capturing all files in memory, e.g.
+//
+//	mem := filesystem.NewMemory()
+//	w := NewDirectoryWriter(".", WithFileSystem(mem))
+func WithFileSystem(fsys filesystem.FS) DirectoryOption {
+	return func(l *fileLayout) {
+		l.fs = fsys
+	}
+}
+
+// WithFileNameTemplate sets the template that renders the path of the file
+// of each composition relative to the directory. Group, Version and Kind
+// are the ones of the composite type. Defaults to <composition name>.yaml.
+func WithFileNameTemplate(t *layout.Template) DirectoryOption {
+	return WithKindFileNameTemplate(xapiextv1.CompositionGroupVersionKind.GroupKind(), t)
+}
+
+// WithKindFileNameTemplate sets the template that renders the path of the
+// files of all objects of the given kind relative to the directory. Group,
+// Version and Kind are the ones of the object, except for compositions.
+// Objects other than compositions default to <lowercase kind>_<name>.yaml.
+func WithKindFileNameTemplate(gk schema.GroupKind, t *layout.Template) DirectoryOption {
+	return func(l *fileLayout) {
+		if l.templates == nil {
+			l.templates = map[schema.GroupKind]*layout.Template{}
+		}
+		l.templates[gk] = t
+	}
+}
+
+// WithFileHeader prefixes every file with text as YAML comment, e.g.
+// provenance.DefaultHeader.
+func WithFileHeader(text string) DirectoryOption {
+	return func(l *fileLayout) {
+		l.header = provenance.Header(text)
+	}
+}
+
+type fileLayout struct {
+	fs        filesystem.FS
+	templates map[schema.GroupKind]*layout.Template
+	header    string
+}
+
+// fileSystem returns the file system of dir.
+func (l fileLayout) fileSystem(dir string) filesystem.FS {
+	if l.fs == nil {
+		return filesystem.NewOS(dir)
+	}
+	return filesystem.Sub(l.fs, dir)
+}
+
+func newFileLayout(opts []DirectoryOption) fileLayout {
+	l := fileLayout{}
+	for _, o := range opts {
+		o(&l)
+	}
+	return l
+}
+
+// fileName returns the slash separated path of the file an object is
+// written to relative to the directory.
+func (l fileLayout) fileName(obj client.Object, builder RegisteredBuilder) (string, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if c, ok := obj.(*xapiextv1.Composition); ok {
+		t := l.templates[xapiextv1.CompositionGroupVersionKind.GroupKind()]
+		if t == nil {
+			return compositionFileName(*c), nil
+		}
+		composite := schema.FromAPIVersionAndKind(c.Spec.CompositeTypeRef.APIVersion, c.Spec.CompositeTypeRef.Kind)
+		return l.execute(t, composite, obj, builder)
+	}
+	if gvk.Kind == "" || gvk.Version == "" {
+		return "", errors.Errorf(errFmtMissingKind, obj.GetName())
+	}
+	t := l.templates[gvk.GroupKind()]
+	if t == nil {
+		return fmt.Sprintf("%s_%s.yaml", strings.ToLower(gvk.Kind), obj.GetName()), nil
+	}
+	return l.execute(t, gvk, obj, builder)
+}
+
+func (l fileLayout) execute(t *layout.Template, gvk schema.GroupVersionKind, obj client.Object, builder RegisteredBuilder) (string, error) {
+	return t.Execute(layout.Data{
+		Group:   gvk.Group,
+		Version: gvk.Version,
+		Kind:    gvk.Kind,
+		Name:    obj.GetName(),
+		Labels:  obj.GetLabels(),
+		Tags:    builder.Tags,
+	})
+}
+
+// marshal returns the content of the file of an object.
+func (l fileLayout) marshal(obj client.Object) ([]byte, error) {
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(l.header), b...), nil
+}
+
 // NewDirectoryWriter creates a new CompositionWriter that writes each
 // composition to the given directory using the objects name as filename.
-func NewDirectoryWriter(dir string) CompositionWriter {
+// The written files are recorded in an index file in the directory. On
+// Finalize, files recorded by a previous run that have not been written
+// again are removed. Files that are not in the index, i.e. hand-written
+// ones, are never touched.
+func NewDirectoryWriter(dir string, opts ...DirectoryOption) CompositionWriter {
+	return newDirectoryWriter(dir, opts)
+}
+
+func newDirectoryWriter(dir string, opts []DirectoryOption) *directoryWriter {
+	l := newFileLayout(opts)
 	return &directoryWriter{
-		dir: dir,
+		fs:     l.fileSystem(dir),
+		layout: l,
 	}
 }
 
 type directoryWriter struct {
-	dir string
+	fs      filesystem.FS
+	layout  fileLayout
+	written []string
+	partial bool
+}
+
+// MarkPartial disables pruning for this writer.
+func (w *directoryWriter) MarkPartial() {
+	w.partial = true
 }
 
 func (w *directoryWriter) Write(c xapiextv1.Composition) error {
-	b, err := yaml.Marshal(c)
+	return w.WriteObject(&c, RegisteredBuilder{})
+}
+
+// WriteObject writes the object to the file rendered for it and its
+// builder.
+func (w *directoryWriter) WriteObject(obj client.Object, builder RegisteredBuilder) error {
+	b, err := w.layout.marshal(obj)
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(w.dir, fs.FileMode(0777)); err != nil {
+	filename, err := w.layout.fileName(obj, builder)
+	if err != nil {
 		return err
 	}
+	for _, f := range w.written {
+		if f == filename {
+			return errors.Errorf(errFmtDuplicateFile, filename, obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
+		}
+	}
+	if err := w.fs.WriteFile(filename, b); err != nil {
+		return err
+	}
+	w.written = append(w.written, filename)
+	return nil
+}
 
-	filename := fmt.Sprintf("%s.yaml", c.GetName())
-	return ioutil.WriteFile(filepath.Join(w.dir, filename), b, fs.FileMode(0664))
+// Finalize removes stale files and updates the index file.
+func (w *directoryWriter) Finalize() error {
+	if w.partial {
+		return index.Extend(w.fs, w.written)
+	}
+	return index.Prune(w.fs, w.written)
+}
+
+// compositionFileName returns the name of the file a composition is written
+// to by the directory writer.
+func compositionFileName(c xapiextv1.Composition) string {
+	return fmt.Sprintf("%s.yaml", c.GetName())
 }
+
+// NewVerifyWriter creates a CompositionWriter that does not write anything
+// but compares the compositions with the files a directory writer would
+// have written to dir.
+// Finalize returns a *verify.Error listing all files that differ, are
+// missing or are extra.
+func NewVerifyWriter(dir string, opts ...DirectoryOption) CompositionWriter {
+	return newVerifyWriter(dir, opts)
+}
+
+func newVerifyWriter(dir string, opts []DirectoryOption) *verifyWriter {
+	l := newFileLayout(opts)
+	return &verifyWriter{
+		fs:     l.fileSystem(dir),
+		layout: l,
+		files:  map[string][]byte{},
+	}
+}
+
+type verifyWriter struct {
+	fs      fs.FS
+	layout  fileLayout
+	files   map[string][]byte
+	partial bool
+}
+
+// MarkPartial disables the detection of extra files.
+func (w *verifyWriter) MarkPartial() {
+	w.partial = true
+}
+
+func (w *verifyWriter) Write(c xapiextv1.Composition) error {
+	return w.WriteObject(&c, RegisteredBuilder{})
+}
+
+// WriteObject records the object for the file rendered for it and its
+// builder.
+func (w *verifyWriter) WriteObject(obj client.Object, builder RegisteredBuilder) error {
+	b, err := w.layout.marshal(obj)
+	if err != nil {
+		return err
+	}
+	filename, err := w.layout.fileName(obj, builder)
+	if err != nil {
+		return err
+	}
+	if _, exists := w.files[filename]; exists {
+		return errors.Errorf(errFmtDuplicateFile, filename, obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
+	}
+	w.files[filename] = b
+	return nil
+}
+
+// Finalize compares the written compositions with the directory.
+func (w *verifyWriter) Finalize() error {
+	isOwned, err := w.ownedFileFilter()
+	if err != nil {
+		return err
+	}
+	res, err := verify.FS(w.fs, w.files, isOwned)
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// ownedFileFilter returns the filter for existing files that are expected to
+// be written. No file is expected in partial mode.
+func (w *verifyWriter) ownedFileFilter() (func(path string) bool, error) {
+	if w.partial {
+		return func(string) bool { return false }, nil
+	}
+	return index.OwnedFilter(w.fs, w.layout.isDirectoryWriterFile)
+}
+
+// isDirectoryWriterFile returns true if the given path could have been
+// written by a directory writer with this layout.
+func (l fileLayout) isDirectoryWriterFile(path string) bool {
+	if !kustomize.IsResourceFile(path) {
+		return false
+	}
+	// Without templates the directory writer does not create
+	// subdirectories.
+	return len(l.templates) > 0 || filepath.Base(path) == path
+}
+
+// NewKustomizeWriter creates a CompositionWriter that works like the one
+// created by NewDirectoryWriter but also creates or updates a
+// kustomization.yaml in dir on Finalize that lists all files of the index,
+// i.e. the files written by this run and, for partial runs, the files
+// written by previous runs.
+func NewKustomizeWriter(dir string, opts kustomize.Options, dirOpts ...DirectoryOption) CompositionWriter {
+	return &kustomizeWriter{
+		directoryWriter: newDirectoryWriter(dir, dirOpts),
+		opts:            opts,
+	}
+}
+
+type kustomizeWriter struct {
+	*directoryWriter
+	opts kustomize.Options
+}
+
+// Finalize prunes stale files and updates the kustomization.
+func (w *kustomizeWriter) Finalize() error {
+	if err := w.directoryWriter.Finalize(); err != nil {
+		return err
+	}
+	owned, _, err := index.Read(w.fs)
+	if err != nil {
+		return err
+	}
+	return kustomize.UpdateFS(w.fs, owned, w.opts)
+}
+
+// NewDiffWriter creates a CompositionWriter that does not write anything
+// but prints the semantic differences between the compositions and the
+// files a directory writer would have written to dir.
+// Resources are matched by their template name, patches by their index.
+func NewDiffWriter(dir string, out io.Writer, format diff.Format, opts ...DirectoryOption) CompositionWriter {
+	return &diffWriter{
+		verifyWriter: *newVerifyWriter(dir, opts),
+		out:          out,
+		format:       format,
+	}
+}
+
+type diffWriter struct {
+	verifyWriter
+	out    io.Writer
+	format diff.Format
+}
+
+// Finalize prints the differences of all written compositions.
+func (w *diffWriter) Finalize() error {
+	isOwned, err := w.ownedFileFilter()
+	if err != nil {
+		return err
+	}
+	report, err := diff.FS(w.fs, w.files, isOwned)
+	if err != nil {
+		return err
+	}
+	return report.Write(w.out, w.format)
+}
+
+var _ ObjectWriter = &multiWriter{}
+var _ ObjectWriter = &writerWriter{}
+var _ ObjectWriter = &directoryWriter{}
+var _ ObjectWriter = &verifyWriter{}
+var _ ObjectWriter = &kustomizeWriter{}
+var _ ObjectWriter = &diffWriter{}
+var _ ObjectWriter = &configurationWriter{}
@@ -0,0 +1,212 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+	"github.com/mistermx/crossbuilder/pkg/generate/index"
+	"github.com/mistermx/crossbuilder/pkg/generate/provenance"
+)
+
+func TestWriterWriter(t *testing.T) {
+	cases := map[string]struct {
+		format WriterFormat
+		names  func(t *testing.T, out string) []string
+	}{
+		"YAML": {
+			format: WriterFormatYAML,
+			names: func(t *testing.T, out string) []string {
+				if !strings.HasPrefix(out, provenance.Header("generated")+yamlDocumentSeparator) {
+					t.Errorf("output does not start with header and separator:\n%s", out)
+				}
+				names := []string{}
+				for _, doc := range strings.Split(out, yamlDocumentSeparator)[1:] {
+					names = append(names, unmarshalName(t, []byte(doc)))
+				}
+				return names
+			},
+		},
+		"JSON": {
+			format: WriterFormatJSON,
+			names: func(t *testing.T, out string) []string {
+				names := []string{}
+				dec := json.NewDecoder(strings.NewReader(out))
+				for dec.More() {
+					c := xapiextv1.Composition{}
+					if err := dec.Decode(&c); err != nil {
+						t.Fatal(err)
+					}
+					names = append(names, c.GetName())
+				}
+				return names
+			},
+		},
+		"JSONLines": {
+			format: WriterFormatJSONLines,
+			names: func(t *testing.T, out string) []string {
+				names := []string{}
+				for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+					names = append(names, unmarshalName(t, []byte(line)))
+				}
+				return names
+			},
+		},
+		"List": {
+			format: WriterFormatList,
+			names: func(t *testing.T, out string) []string {
+				list := metav1.List{}
+				if err := yaml.Unmarshal([]byte(strings.TrimPrefix(out, provenance.Header("generated"))), &list); err != nil {
+					t.Fatal(err)
+				}
+				if list.Kind != "List" {
+					t.Errorf("kind: want List, got %q", list.Kind)
+				}
+				names := []string{}
+				for _, item := range list.Items {
+					names = append(names, unmarshalName(t, item.Raw))
+				}
+				return names
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w, err := NewWriterWriter(buf, WithWriterFormat(tc.format), WithWriterHeader("generated"))
+			if err != nil {
+				t.Fatalf("NewWriterWriter(...): %v", err)
+			}
+			err = NewRunner(RunnerConfig{
+				Builder: []CompositionBuilder{testBuilder{name: "a"}, testBuilder{name: "b"}},
+				Writer:  w,
+			}).Build()
+			if err != nil {
+				t.Fatalf("Build(): %v", err)
+			}
+			if diff := cmp.Diff([]string{"a", "b"}, tc.names(t, buf.String())); diff != "" {
+				t.Errorf("Build(): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriterFormatValidate(t *testing.T) {
+	if err := WriterFormat("xml").Validate(); err == nil {
+		t.Errorf("Validate(): want error for unknown format")
+	}
+}
+
+func TestNewWriterWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriterWriter(&bytes.Buffer{}, WithWriterFormat("xml")); err == nil {
+		t.Errorf("NewWriterWriter(...): want error for unknown format")
+	}
+}
+
+func unmarshalName(t *testing.T, b []byte) string {
+	t.Helper()
+	c := xapiextv1.Composition{}
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	return c.GetName()
+}
+
+// objectBuilder builds a composition together with a typed client.Object.
+type objectBuilder struct {
+	testBuilder
+}
+
+func (objectBuilder) BuildObjects() ([]client.Object, error) {
+	return []client.Object{&corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "config"},
+		Data:       map[string]string{"key": "value"},
+	}}, nil
+}
+
+func TestDirectoryWriterObjects(t *testing.T) {
+	fsys := filesystem.NewMemory()
+	err := NewRunner(RunnerConfig{
+		Builder: []CompositionBuilder{objectBuilder{testBuilder{name: "a"}}},
+		Writer:  NewDirectoryWriter("", WithFileSystem(fsys)),
+	}).Build()
+	if err != nil {
+		t.Fatalf("Build(): %v", err)
+	}
+	if diff := cmp.Diff([]string{".crossbuilder-index", "a.yaml", "configmap_config.yaml"}, fileNames(fsys)); diff != "" {
+		t.Errorf("Build(): -want, +got:\n%s", diff)
+	}
+	data, err := fsys.ReadFile("configmap_config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := yaml.Unmarshal(data, cm); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"key": "value"}, cm.Data); diff != "" {
+		t.Errorf("configmap_config.yaml: -want, +got:\n%s", diff)
+	}
+}
+
+func TestDirectoryWriterPrune(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		filter BuilderFilter
+		want   []string
+	}{
+		"Full": {
+			reason: "A full run removes owned files that have not been written again.",
+			want:   []string{index.FileName, "a.yaml", "hand-written.yaml"},
+		},
+		"Partial": {
+			reason: "A filtered run must never remove files.",
+			filter: BuilderFilter{CompositeTypes: []string{"XTest.example.org"}},
+			want:   []string{index.FileName, "a.yaml", "b.yaml", "hand-written.yaml"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fsys := filesystem.NewMemory()
+			if err := fsys.WriteFile("hand-written.yaml", []byte("kind: Composition\n")); err != nil {
+				t.Fatal(err)
+			}
+			run := func(builders []CompositionBuilder, filter BuilderFilter) {
+				err := NewRunner(RunnerConfig{
+					Builder: builders,
+					Writer:  NewDirectoryWriter(".", WithFileSystem(fsys)),
+					Filter:  filter,
+				}).Build()
+				if err != nil {
+					t.Fatalf("\n%s\nBuild(): %v", tc.reason, err)
+				}
+			}
+			run([]CompositionBuilder{testBuilder{name: "a"}, testBuilder{name: "b"}}, BuilderFilter{})
+			run([]CompositionBuilder{testBuilder{name: "a"}}, tc.filter)
+
+			if diff := cmp.Diff(tc.want, fileNames(fsys)); diff != "" {
+				t.Errorf("\n%s\nBuild(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func fileNames(fsys *filesystem.Memory) []string {
+	names := []string{}
+	for name := range fsys.Files() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
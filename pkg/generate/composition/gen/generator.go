@@ -0,0 +1,272 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/composition/build"
+)
+
+const (
+	errNoPaths        = "no paths specified"
+	errLoadPackages   = "failed to load packages"
+	errRenderMain     = "failed to render main package"
+	errCreateTempDir  = "failed to create temporary directory"
+	errWriteMain      = "failed to write main package"
+	errWriteOverlay   = "failed to write build overlay"
+	errGetWorkingDir  = "failed to get working directory"
+	errRunMain        = "failed to run generated main package"
+	errBuilderIfcType = "CompositionBuilder is not an interface"
+
+	builderTypeName = "CompositionBuilder"
+	tempDirPattern  = "composition-gen-*"
+	mainFileName    = "main.go"
+	overlayFileName = "overlay.json"
+
+	// mainPkgDir is the directory in the current module the main package
+	// appears in for the go command. The directory only exists in the build
+	// overlay. The underscore prefix makes the go command ignore it in
+	// patterns like ./... anyway.
+	mainPkgDir = "_composition-gen"
+)
+
+var (
+	buildPkgPath = reflect.TypeOf((*build.CompositionBuilder)(nil)).Elem().PkgPath()
+	genPkgPath   = reflect.TypeOf(Options{}).PkgPath()
+)
+
+var mainTemplate = template.Must(template.New("main").Parse(`// Code generated by composition-gen. DO NOT EDIT.
+
+package main
+
+import (
+	"log"
+	"os"
+
+	build "{{ .BuildPkg }}"
+	gen "{{ .GenPkg }}"
+{{ range .Imports }}
+	{{ .Alias }} "{{ .Path }}"
+{{- end }}
+)
+
+func main() {
+	exported := []build.CompositionBuilder{
+{{- range $imp := .Imports }}{{ range .Types }}
+		&{{ $imp.Alias }}.{{ . }}{},
+{{- end }}{{ end }}
+	}
+	if err := gen.Run(exported, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+`))
+
+type mainData struct {
+	BuildPkg string
+	GenPkg   string
+	Imports  []builderImport
+}
+
+// builderImport is a package that is imported by the generated main package
+// together with the exported builder types it declares.
+type builderImport struct {
+	Alias string
+	Path  string
+	Types []string
+}
+
+// Generate loads the packages matched by the paths option and generates a
+// temporary main package that imports all of them, so their builders can
+// register themselves. If enabled, exported builder types are collected as
+// well. The main package is then run with the given options and removed afterwards.
+func Generate(rawOpts []string) error {
+	opts, err := ParseOptions(rawOpts)
+	if err != nil {
+		return err
+	}
+	if len(opts.Paths) == 0 {
+		return errors.New(errNoPaths)
+	}
+
+	imports, err := loadBuilderImports(opts.Paths, opts.Generator.Exported)
+	if err != nil {
+		return err
+	}
+
+	dir, err := createMainDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+	return runMain(dir, imports, rawOpts)
+}
+
+// createMainDir creates the temporary directory of the main package and its
+// build overlay outside of the current module.
+func createMainDir() (string, error) {
+	dir, err := os.MkdirTemp("", tempDirPattern)
+	return dir, errors.Wrap(err, errCreateTempDir)
+}
+
+// buildOverlay is the JSON file passed to the -overlay flag of the go
+// command.
+type buildOverlay struct {
+	Replace map[string]string
+}
+
+// runMain renders the main package for the given imports into dir and runs
+// it with the given options. The main package must be part of the current
+// module to import the builder packages, so it is mapped into mainPkgDir of
+// the working directory using a build overlay.
+func runMain(dir string, imports []builderImport, rawOpts []string) error {
+	src, err := renderMain(imports)
+	if err != nil {
+		return errors.Wrap(err, errRenderMain)
+	}
+	mainFile := filepath.Join(dir, mainFileName)
+	if err := os.WriteFile(mainFile, src, 0600); err != nil {
+		return errors.Wrap(err, errWriteMain)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return errors.Wrap(err, errGetWorkingDir)
+	}
+	overlay, err := json.Marshal(buildOverlay{
+		Replace: map[string]string{
+			filepath.Join(wd, mainPkgDir, mainFileName): mainFile,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, errWriteOverlay)
+	}
+	overlayFile := filepath.Join(dir, overlayFileName)
+	if err := os.WriteFile(overlayFile, overlay, 0600); err != nil {
+		return errors.Wrap(err, errWriteOverlay)
+	}
+
+	args := append([]string{"run", "-overlay", overlayFile, "./" + mainPkgDir}, rawOpts...)
+	cmd := exec.Command("go", args...) //nolint:gosec
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return errors.Wrap(cmd.Run(), errRunMain)
+}
+
+// loadBuilderImports loads the packages matching the given paths. If exported
+// is true, the exported builder types of each package are collected.
+func loadBuilderImports(paths []string, exported bool) ([]builderImport, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName,
+	}
+	if exported {
+		// Types are loaded from export data since we only need to know
+		// which types implement CompositionBuilder. The imported packages
+		// are needed as well, since the export data of a package only
+		// contains the parts of its imports it uses itself.
+		cfg.Mode |= packages.NeedTypes | packages.NeedImports | packages.NeedDeps
+	}
+	roots, err := packages.Load(cfg, paths...)
+	if err != nil {
+		return nil, errors.Wrap(err, errLoadPackages)
+	}
+	if packages.PrintErrors(roots) > 0 {
+		return nil, errors.New(errLoadPackages)
+	}
+
+	imports := []builderImport{}
+	for _, root := range roots {
+		if root.Name == "main" {
+			// main packages cannot be imported.
+			continue
+		}
+		imp := builderImport{
+			Path: root.PkgPath,
+		}
+		if exported {
+			builderTypes, err := findExportedBuilders(root)
+			if err != nil {
+				return nil, err
+			}
+			imp.Types = builderTypes
+		}
+		imports = append(imports, imp)
+	}
+	return imports, nil
+}
+
+// findExportedBuilders returns the names of all exported, non-generic struct
+// types in pkg whose pointer implements CompositionBuilder.
+func findExportedBuilders(pkg *packages.Package) ([]string, error) {
+	if pkg.Types == nil {
+		return nil, nil
+	}
+	buildPkg := pkg.Imports[buildPkgPath]
+	if buildPkg == nil || buildPkg.Types == nil {
+		// Packages that do not import the build package cannot declare
+		// builders.
+		return nil, nil
+	}
+	obj := buildPkg.Types.Scope().Lookup(builderTypeName)
+	if obj == nil {
+		return nil, nil
+	}
+	builderIfc, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, errors.New(errBuilderIfcType)
+	}
+
+	builders := []string{}
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		typeName, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !typeName.Exported() || typeName.IsAlias() {
+			continue
+		}
+		named, ok := typeName.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		if _, isStruct := named.Underlying().(*types.Struct); !isStruct {
+			continue
+		}
+		if types.Implements(types.NewPointer(named), builderIfc) {
+			builders = append(builders, name)
+		}
+	}
+	return builders, nil
+}
+
+// renderMain renders the source code of the temporary main package.
+func renderMain(imports []builderImport) ([]byte, error) {
+	imports = append([]builderImport(nil), imports...)
+	for i := range imports {
+		imports[i].Alias = "_"
+		if len(imports[i].Types) > 0 {
+			// Use generic aliases so package names cannot clash.
+			imports[i].Alias = fmt.Sprintf("pkg%d", i)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := mainTemplate.Execute(buf, mainData{
+		BuildPkg: buildPkgPath,
+		GenPkg:   genPkgPath,
+		Imports:  imports,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,79 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	xapiextv1 "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/index"
+)
+
+func TestRenderMain(t *testing.T) {
+	src, err := renderMain([]builderImport{
+		{Path: "example.org/compositions/registered"},
+		{Path: "example.org/compositions/exported", Types: []string{"NetworkBuilder"}},
+	})
+	if err != nil {
+		t.Fatalf("renderMain(...): %v", err)
+	}
+	for _, want := range []string{
+		`_ "example.org/compositions/registered"`,
+		`pkg1 "example.org/compositions/exported"`,
+		`&pkg1.NetworkBuilder{},`,
+		`gen.Run(exported, os.Args[1:])`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("renderMain(...): missing %q in:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+	out := t.TempDir()
+	err := Generate([]string{
+		"paths=./testdata/builders/registered",
+		"paths=./testdata/builders/exported",
+		"composition:exported=true",
+		"output:dir=" + out,
+	})
+	if err != nil {
+		t.Fatalf("Generate(...): %v", err)
+	}
+
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{index.FileName, "exported.yaml", "registered.yaml"}
+	if diff := cmp.Diff(want, sorted(names)); diff != "" {
+		t.Errorf("Generate(...): files: -want, +got:\n%s", diff)
+	}
+	for _, name := range []string{"exported", "registered"} {
+		b, err := os.ReadFile(filepath.Join(out, name+".yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &xapiextv1.Composition{}
+		if err := yaml.Unmarshal(b, c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Kind != xapiextv1.CompositionKind || c.GetName() != name {
+			t.Errorf("Generate(...): %s.yaml: want composition %s, got %s %s", name, name, c.Kind, c.GetName())
+		}
+	}
+	if _, err := os.Stat(mainPkgDir); !os.IsNotExist(err) {
+		t.Errorf("Generate(...): main package must only exist in the build overlay, got %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package gen
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/composition/build"
+	"github.com/mistermx/crossbuilder/pkg/generate/diff"
+	"github.com/mistermx/crossbuilder/pkg/generate/kustomize"
+	"github.com/mistermx/crossbuilder/pkg/generate/layout"
+	"github.com/mistermx/crossbuilder/pkg/generate/provenance"
+)
+
+const (
+	errFmtUnknownOption = "unknown option %q"
+	errFmtParseOption   = "unable to parse option %q"
+	errFmtOptionMarker  = "unknown option marker %q"
+	errFmtProvider      = "invalid provider mapping %q, expected GROUP=PROVIDER@VERSION"
+
+	defaultOutputDir = "package/compositions"
+)
+
+// Generator contains the options of the composition generator.
+type Generator struct {
+	// Exported enables the discovery of exported types that implement
+	// CompositionBuilder. Each discovered type is instantiated with its zero
+	// value unless a builder of the same type has been registered, so only
+	// enable it if all exported builder types work without any fields set.
+	//
+	// Left unspecified, the default is false.
+	Exported bool `marker:",optional"`
+
+	// Parallelism is the maximum number of compositions that are built
+	// concurrently.
+	//
+	// Left unspecified, the number of CPUs is used.
+	Parallelism int `marker:",optional"`
+
+	// Names only writes compositions whose name matches one of the given
+	// glob patterns. All builders that are selected by the other filters
+	// must implement build.CompositionNamer.
+	Names []string `marker:",optional"`
+
+	// CompositeTypes only builds compositions for one of the given
+	// composite kinds in the form Kind.group or Kind.version.group.
+	CompositeTypes []string `marker:",optional"`
+
+	// Tags only builds compositions of builders that have been registered
+	// with one of the given tags.
+	Tags []string `marker:",optional"`
+
+	// Report is the path of a file a JSON build report is written to.
+	Report string `marker:",optional"`
+
+	// FileName is a Go text/template for the path of each composition
+	// relative to the output directory, e.g.
+	// {{ .Kind | lower }}/{{ index .Labels "variant" }}.yaml. The template
+	// is executed with layout.Data.
+	//
+	// Left unspecified, <composition name>.yaml is used.
+	FileName string `marker:",optional"`
+
+	// Provenance adds the generator version, the Go type of the builder and
+	// the hash of the spec as annotations to every composition.
+	Provenance bool `marker:",optional"`
+
+	// Header prefixes every file written to a directory with a comment that
+	// marks it as generated.
+	Header bool `marker:",optional"`
+}
+
+// DirectoryOptions returns the options of the directories compositions are
+// written to.
+func (g Generator) DirectoryOptions() ([]build.DirectoryOption, error) {
+	opts := []build.DirectoryOption{}
+	if g.FileName != "" {
+		t, err := layout.Parse(g.FileName)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, build.WithFileNameTemplate(t))
+	}
+	if g.Header {
+		opts = append(opts, build.WithFileHeader(provenance.DefaultHeader))
+	}
+	return opts, nil
+}
+
+// Filter returns the builder filter of these options.
+func (g Generator) Filter() build.BuilderFilter {
+	return build.BuilderFilter{
+		Names:          g.Names,
+		CompositeTypes: g.CompositeTypes,
+		Tags:           g.Tags,
+	}
+}
+
+// Configuration generates the Crossplane Configuration package metadata
+// for the generated compositions. It is not written in verify and diff mode.
+type Configuration struct {
+	// File is the path the metadata is written to.
+	File string
+
+	// Name is the name of the Configuration.
+	Name string
+
+	// Providers map API groups of composed resources to provider packages
+	// in the form GROUP=PROVIDER@VERSION, e.g.
+	// *.aws.upbound.io=xpkg.upbound.io/upbound/provider-aws@>=v0.40.0.
+	// Groups that are mapped to an empty provider do not add a dependency.
+	Providers []string `marker:",optional"`
+
+	// CrossplaneVersion is the minimum Crossplane version, e.g. v1.14.0.
+	CrossplaneVersion string `marker:",optional"`
+}
+
+// Writer returns a configuration writer for these options.
+func (c Configuration) Writer() (build.CompositionWriter, error) {
+	mappings := make([]build.ProviderMapping, len(c.Providers))
+	for i, raw := range c.Providers {
+		group, pkg, ok := strings.Cut(raw, "=")
+		if !ok {
+			return nil, errors.Errorf(errFmtProvider, raw)
+		}
+		mappings[i] = build.ProviderMapping{
+			Group: group,
+		}
+		if pkg == "" {
+			continue
+		}
+		idx := strings.LastIndex(pkg, "@")
+		if idx < 0 {
+			return nil, errors.Errorf(errFmtProvider, raw)
+		}
+		mappings[i].Provider = pkg[:idx]
+		mappings[i].Version = pkg[idx+1:]
+	}
+	return build.NewConfigurationWriter(c.File, build.ConfigurationOptions{
+		Name:              c.Name,
+		Providers:         mappings,
+		CrossplaneVersion: c.CrossplaneVersion,
+	}), nil
+}
+
+// OutputRule creates the writer generated compositions are written to.
+type OutputRule interface {
+	// Writer returns the CompositionWriter for this rule. Rules that write
+	// to or compare with directories use the given options.
+	Writer(opts ...build.DirectoryOption) (build.CompositionWriter, error)
+}
+
+// OutputToDirectory writes each composition to a file in the given
+// directory.
+type OutputToDirectory string
+
+// Writer returns a directory writer for this rule.
+func (o OutputToDirectory) Writer(opts ...build.DirectoryOption) (build.CompositionWriter, error) {
+	return build.NewDirectoryWriter(string(o), opts...), nil
+}
+
+// OutputKustomizeDirectory writes each composition to a file in the given
+// directory and maintains a kustomization.yaml that lists the generated
+// files.
+type OutputKustomizeDirectory struct {
+	// Dir is the directory to write to.
+	Dir string
+
+	// CommonLabels are added to the kustomization.
+	CommonLabels map[string]string `marker:",optional"`
+}
+
+// Writer returns a kustomize writer for this rule.
+func (o OutputKustomizeDirectory) Writer(opts ...build.DirectoryOption) (build.CompositionWriter, error) {
+	return build.NewKustomizeWriter(o.Dir, kustomize.Options{CommonLabels: o.CommonLabels}, opts...), nil
+}
+
+// OutputVerifyDirectory does not write anything but fails if the files in
+// the given directory are not up to date.
+type OutputVerifyDirectory string
+
+// Writer returns a verifying writer for this rule.
+func (o OutputVerifyDirectory) Writer(opts ...build.DirectoryOption) (build.CompositionWriter, error) {
+	return build.NewVerifyWriter(string(o), opts...), nil
+}
+
+// OutputDiffDirectory does not write anything but prints the semantic
+// differences between the generated compositions and the files in the given
+// directory to standard-out.
+type OutputDiffDirectory struct {
+	// Dir is the directory to compare with.
+	Dir string
+
+	// Format is the output format, either text or json.
+	Format string `marker:",optional"`
+}
+
+// Writer returns a diff writer for this rule.
+func (o OutputDiffDirectory) Writer(opts ...build.DirectoryOption) (build.CompositionWriter, error) {
+	return build.NewDiffWriter(o.Dir, os.Stdout, diff.Format(o.Format), opts...), nil
+}
+
+// OutputToStdout writes all compositions to standard-out.
+var OutputToStdout = outputToStdout{}
+
+// outputToStdout writes all compositions to standard-out.
+type outputToStdout struct {
+	// Format is the output format, one of yaml, json, jsonl or list.
+	//
+	// Left unspecified, a multi-document YAML stream is written.
+	Format string `marker:",optional"`
+}
+
+// Writer returns a writer that writes to standard-out. Directory options
+// are ignored.
+func (o outputToStdout) Writer(...build.DirectoryOption) (build.CompositionWriter, error) {
+	format := build.WriterFormatYAML
+	if o.Format != "" {
+		format = build.WriterFormat(o.Format)
+	}
+	return build.NewWriterWriter(os.Stdout, build.WithWriterFormat(format))
+}
+
+// Options are the parsed command line options of the composition generator.
+type Options struct {
+	// Paths are the package patterns that contain the builders.
+	Paths []string
+
+	// Generator are the generator options.
+	Generator Generator
+
+	// Output is the output rule compositions are written with.
+	Output OutputRule
+
+	// Configuration are the options of the generated Configuration package
+	// metadata. Nil if none should be generated.
+	Configuration *Configuration
+}
+
+// OptionsRegistry returns a registry that contains all option markers of the
+// composition generator.
+//
+// Options use the same syntax as controller-gen, i.e.
+//
+//	paths=./compositions/... composition:parallelism=4 output:dir=./package/compositions
+func OptionsRegistry() (*markers.Registry, error) {
+	reg := &markers.Registry{}
+	defs := []*markers.Definition{
+		genall.InputPathsMarker,
+	}
+	for name, obj := range map[string]interface{}{
+		"composition":      Generator{},
+		"configuration":    Configuration{},
+		"output:dir":       OutputToDirectory(""),
+		"output:kustomize": OutputKustomizeDirectory{},
+		"output:stdout":    OutputToStdout,
+		"output:verify":    OutputVerifyDirectory(""),
+		"output:diff":      OutputDiffDirectory{},
+	} {
+		def, err := markers.MakeDefinition(name, markers.DescribesPackage, obj)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	for _, def := range defs {
+		if err := reg.Register(def); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+// ParseOptions parses the given raw command line options.
+func ParseOptions(rawOpts []string) (Options, error) {
+	reg, err := OptionsRegistry()
+	if err != nil {
+		return Options{}, err
+	}
+
+	opts := Options{
+		Output: OutputToDirectory(defaultOutputDir),
+	}
+	for _, rawOpt := range rawOpts {
+		if rawOpt == "" {
+			continue
+		}
+		if rawOpt[0] != '+' {
+			rawOpt = "+" + rawOpt // add a `+` to make it acceptable for usage with the registry
+		}
+		defn := reg.Lookup(rawOpt, markers.DescribesPackage)
+		if defn == nil {
+			return Options{}, errors.Errorf(errFmtUnknownOption, rawOpt[1:])
+		}
+		val, err := defn.Parse(rawOpt)
+		if err != nil {
+			return Options{}, errors.Wrapf(err, errFmtParseOption, rawOpt[1:])
+		}
+
+		switch val := val.(type) {
+		case genall.InputPaths:
+			opts.Paths = append(opts.Paths, val...)
+		case Generator:
+			opts.Generator = val
+		case Configuration:
+			opts.Configuration = &val
+		case OutputRule:
+			opts.Output = val
+		default:
+			return Options{}, errors.Errorf(errFmtOptionMarker, defn.Name)
+		}
+	}
+	return opts, nil
+}
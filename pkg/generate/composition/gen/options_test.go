@@ -0,0 +1,62 @@
+package gen
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseOptions(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		rawOpts []string
+		want    Options
+	}{
+		"Defaults": {
+			reason:  "Exported builder discovery must be disabled by default.",
+			rawOpts: []string{"paths=./..."},
+			want: Options{
+				Paths:  []string{"./..."},
+				Output: OutputToDirectory(defaultOutputDir),
+			},
+		},
+		"Exported": {
+			reason:  "Exported builder discovery can be enabled.",
+			rawOpts: []string{"paths=./...", "composition:exported=true", "output:dir=./out"},
+			want: Options{
+				Paths:     []string{"./..."},
+				Generator: Generator{Exported: true},
+				Output:    OutputToDirectory("./out"),
+			},
+		},
+		"Filter": {
+			reason:  "Filter options are parsed into the generator options.",
+			rawOpts: []string{"paths=./...", "composition:names=aws-*,tags=network", "output:stdout"},
+			want: Options{
+				Paths: []string{"./..."},
+				Generator: Generator{
+					Names: []string{"aws-*"},
+					Tags:  []string{"network"},
+				},
+				Output: OutputToStdout,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseOptions(tc.rawOpts)
+			if err != nil {
+				t.Fatalf("\n%s\nParseOptions(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(outputToStdout{})); diff != "" {
+				t.Errorf("\n%s\nParseOptions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestParseOptionsUnknown(t *testing.T) {
+	if _, err := ParseOptions([]string{"output:unknown"}); err == nil {
+		t.Errorf("ParseOptions(...): want error for unknown option")
+	}
+}
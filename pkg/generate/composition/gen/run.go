@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/composition/build"
+)
+
+const (
+	errCreateReport = "failed to create report file"
+)
+
+// Run builds all compositions of the builders in the build.DefaultRegistry
+// and the given exported builders and writes them using the output rule of
+// the given options.
+// Exported builders are skipped if a builder of the same type has been
+// registered. Run is called by the main package rendered by Generate.
+func Run(exported []build.CompositionBuilder, rawOpts []string) error {
+	opts, err := ParseOptions(rawOpts)
+	if err != nil {
+		return err
+	}
+	dirOpts, err := opts.Generator.DirectoryOptions()
+	if err != nil {
+		return err
+	}
+	writer, err := opts.Output.Writer(dirOpts...)
+	if err != nil {
+		return err
+	}
+	if opts.Configuration != nil && !isCheckOnly(opts.Output) {
+		cfgWriter, err := opts.Configuration.Writer()
+		if err != nil {
+			return err
+		}
+		writer = build.NewMultiWriter(writer, cfgWriter)
+	}
+
+	registry := build.DefaultRegistry
+	builders := []build.CompositionBuilder{}
+	for _, b := range exported {
+		if !registry.ContainsType(b) {
+			builders = append(builders, b)
+		}
+	}
+
+	cfg := build.RunnerConfig{
+		Builder:     builders,
+		Registry:    registry,
+		Writer:      writer,
+		Parallelism: opts.Generator.Parallelism,
+		Filter:      opts.Generator.Filter(),
+		Provenance:  opts.Generator.Provenance,
+	}
+	if opts.Generator.Report != "" {
+		f, err := os.Create(opts.Generator.Report)
+		if err != nil {
+			return errors.Wrap(err, errCreateReport)
+		}
+		defer f.Close() //nolint:errcheck
+		cfg.Report = f
+	}
+	return build.NewRunner(cfg).Build()
+}
+
+// isCheckOnly returns true if the given output rule does not write any
+// files.
+func isCheckOnly(o OutputRule) bool {
+	switch o.(type) {
+	case OutputVerifyDirectory, OutputDiffDirectory:
+		return true
+	}
+	return false
+}
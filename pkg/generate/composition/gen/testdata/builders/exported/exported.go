@@ -0,0 +1,21 @@
+// Package exported contains an exported builder that does not register
+// itself.
+package exported
+
+import (
+	"github.com/mistermx/crossbuilder/examples/xrd-gen/apis/v1alpha1"
+	"github.com/mistermx/crossbuilder/pkg/generate/composition/build"
+)
+
+type Builder struct{}
+
+func (b *Builder) GetCompositeTypeRef() build.ObjectKindReference {
+	return build.ObjectKindReference{
+		GroupVersionKind: v1alpha1.XExampleGroupVersionKind,
+		Object:           &v1alpha1.XExample{},
+	}
+}
+
+func (b *Builder) Build(c build.CompositionSkeleton) {
+	c.WithName("exported")
+}
@@ -0,0 +1,24 @@
+// Package registered contains a builder that registers itself.
+package registered
+
+import (
+	"github.com/mistermx/crossbuilder/examples/xrd-gen/apis/v1alpha1"
+	"github.com/mistermx/crossbuilder/pkg/generate/composition/build"
+)
+
+func init() {
+	build.Register(&builder{})
+}
+
+type builder struct{}
+
+func (b *builder) GetCompositeTypeRef() build.ObjectKindReference {
+	return build.ObjectKindReference{
+		GroupVersionKind: v1alpha1.XExampleGroupVersionKind,
+		Object:           &v1alpha1.XExample{},
+	}
+}
+
+func (b *builder) Build(c build.CompositionSkeleton) {
+	c.WithName("registered")
+}
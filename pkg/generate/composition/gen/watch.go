@@ -0,0 +1,195 @@
+package gen
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/watch"
+)
+
+// Watch runs Generate once and then every time one of the packages matched
+// by the paths option or a package of the current module they import
+// changes, until ctx is done. Errors are printed to out.
+//
+// Packages and their exported builders are cached between runs. Only the
+// packages that are affected by a change are loaded again. Like
+// watch.Generators, new packages matched by the paths option are only
+// picked up after a restart.
+func Watch(ctx context.Context, rawOpts []string, out io.Writer) error {
+	opts, err := ParseOptions(rawOpts)
+	if err != nil {
+		return err
+	}
+	if len(opts.Paths) == 0 {
+		return errors.New(errNoPaths)
+	}
+
+	dir, err := createMainDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	cache := &builderCache{
+		exported: opts.Generator.Exported,
+		imports:  map[string]builderImport{},
+	}
+	return watch.Run(ctx, out, func(changed []string) ([]string, error) {
+		imports, dirs, err := cache.load(opts.Paths, changed)
+		if err != nil {
+			return dirs, err
+		}
+		return dirs, runMain(dir, imports, rawOpts)
+	})
+}
+
+// builderCache caches the loaded packages and their builder imports.
+type builderCache struct {
+	exported bool
+
+	// roots are the packages matching the paths option together with their
+	// dependencies. They are loaded on the first run and afterwards only
+	// the roots that are affected by a change are loaded again.
+	roots   []*packages.Package
+	imports map[string]builderImport
+}
+
+// load returns the builder imports of the packages matching paths and the
+// directories of these packages and the packages of the current module they
+// import. Only roots that are affected by the changed directories are loaded
+// again, and builder imports are only collected for packages that have been
+// loaded again or are not cached yet.
+func (c *builderCache) load(paths []string, changed []string) ([]builderImport, []string, error) {
+	if err := c.reload(paths, changed); err != nil {
+		return nil, nil, err
+	}
+
+	allDirs := map[string]bool{}
+	order := []string{}
+	stale := []string{}
+	for _, root := range c.roots {
+		for dir := range localDirs(root) {
+			allDirs[dir] = true
+		}
+		if root.Name == "main" {
+			continue
+		}
+		order = append(order, root.PkgPath)
+		if _, cached := c.imports[root.PkgPath]; !cached {
+			stale = append(stale, root.PkgPath)
+		}
+	}
+	dirs := make([]string, 0, len(allDirs))
+	for dir := range allDirs {
+		dirs = append(dirs, dir)
+	}
+
+	if packages.PrintErrors(c.roots) > 0 {
+		return nil, dirs, errors.New(errLoadPackages)
+	}
+	if len(stale) > 0 {
+		loaded, err := loadBuilderImports(stale, c.exported)
+		if err != nil {
+			return nil, dirs, err
+		}
+		for _, imp := range loaded {
+			c.imports[imp.Path] = imp
+		}
+	}
+
+	imports := make([]builderImport, 0, len(order))
+	for _, path := range order {
+		if imp, ok := c.imports[path]; ok {
+			imports = append(imports, imp)
+		}
+	}
+	return imports, dirs, nil
+}
+
+// reload loads all roots on the first call and afterwards only the roots
+// that are affected by changes in the given directories. The builder
+// imports of reloaded roots are removed from the cache.
+func (c *builderCache) reload(paths []string, changed []string) error {
+	if c.roots == nil {
+		roots, err := loadRoots(paths...)
+		if err != nil {
+			return err
+		}
+		c.roots = roots
+		return nil
+	}
+
+	changedDirs := make(map[string]bool, len(changed))
+	for _, dir := range changed {
+		changedDirs[dir] = true
+	}
+	affected := []string{}
+	for _, root := range c.roots {
+		if containsAny(localDirs(root), changedDirs) {
+			affected = append(affected, root.PkgPath)
+		}
+	}
+	if len(affected) == 0 {
+		return nil
+	}
+
+	reloaded, err := loadRoots(affected...)
+	if err != nil {
+		return err
+	}
+	byPath := make(map[string]*packages.Package, len(reloaded))
+	for _, pkg := range reloaded {
+		byPath[pkg.PkgPath] = pkg
+	}
+	for i, root := range c.roots {
+		if pkg, ok := byPath[root.PkgPath]; ok {
+			c.roots[i] = pkg
+			delete(c.imports, root.PkgPath)
+		}
+	}
+	return nil
+}
+
+// loadRoots loads the packages matching the given patterns together with
+// the imports needed to find their local directories.
+func loadRoots(patterns ...string) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedModule,
+	}
+	roots, err := packages.Load(cfg, patterns...)
+	return roots, errors.Wrap(err, errLoadPackages)
+}
+
+// localDirs returns the directories of pkg and all packages of the main
+// module it imports directly or indirectly.
+func localDirs(pkg *packages.Package) map[string]bool {
+	dirs := map[string]bool{}
+	visited := map[*packages.Package]bool{}
+	var visit func(p *packages.Package)
+	visit = func(p *packages.Package) {
+		if visited[p] || p.Module == nil || !p.Module.Main || len(p.GoFiles) == 0 {
+			return
+		}
+		visited[p] = true
+		dirs[filepath.Dir(p.GoFiles[0])] = true
+		for _, imp := range p.Imports {
+			visit(imp)
+		}
+	}
+	visit(pkg)
+	return dirs
+}
+
+func containsAny(set, values map[string]bool) bool {
+	for v := range values {
+		if set[v] {
+			return true
+		}
+	}
+	return false
+}
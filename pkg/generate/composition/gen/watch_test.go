@@ -0,0 +1,97 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuilderCacheReload(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":   "module example.org/test\n\ngo 1.21\n",
+		"a/a.go":   "package a\n\nimport _ \"example.org/test/b\"\n",
+		"b/b.go":   "package b\n",
+		"c/c.go":   "package c\n",
+		"d/d.go":   "package d\n",
+		"cmd/x.go": "package main\n\nfunc main() {}\n",
+	})
+	t.Chdir(dir)
+
+	cache := &builderCache{imports: map[string]builderImport{}}
+	imports, dirs, err := cache.load([]string{"./a", "./c", "./cmd"}, nil)
+	if err != nil {
+		t.Fatalf("load(...): %v", err)
+	}
+	if diff := cmp.Diff([]string{"example.org/test/a", "example.org/test/c"}, importPaths(imports)); diff != "" {
+		t.Errorf("load(...): imports: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(localPaths(dir, "a", "b", "c", "cmd"), sorted(dirs)); diff != "" {
+		t.Errorf("load(...): dirs: -want, +got:\n%s", diff)
+	}
+	rootC := cache.roots[1]
+
+	// a now imports d instead of b.
+	writeFiles(t, dir, map[string]string{
+		"a/a.go": "package a\n\nimport _ \"example.org/test/d\"\n",
+	})
+	imports, dirs, err = cache.load([]string{"./a", "./c", "./cmd"}, []string{filepath.Join(dir, "a")})
+	if err != nil {
+		t.Fatalf("load(...): %v", err)
+	}
+	if diff := cmp.Diff([]string{"example.org/test/a", "example.org/test/c"}, importPaths(imports)); diff != "" {
+		t.Errorf("load(...): imports: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(localPaths(dir, "a", "c", "cmd", "d"), sorted(dirs)); diff != "" {
+		t.Errorf("load(...): dirs: -want, +got:\n%s", diff)
+	}
+	if cache.roots[1] != rootC {
+		t.Errorf("load(...): unaffected package example.org/test/c has been loaded again")
+	}
+
+	// Changes in directories no root depends on anymore are ignored.
+	if _, _, err := cache.load([]string{"./a", "./c", "./cmd"}, []string{filepath.Join(dir, "b")}); err != nil {
+		t.Fatalf("load(...): %v", err)
+	}
+	if cache.roots[1] != rootC {
+		t.Errorf("load(...): unaffected package example.org/test/c has been loaded again")
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func importPaths(imports []builderImport) []string {
+	paths := make([]string, 0, len(imports))
+	for _, imp := range imports {
+		paths = append(paths, imp.Path)
+	}
+	return paths
+}
+
+func localPaths(dir string, names ...string) []string {
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	return paths
+}
+
+func sorted(s []string) []string {
+	s = append([]string(nil), s...)
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,328 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	kindComposition = "Composition"
+	kindXRD         = "CompositeResourceDefinition"
+
+	// maxValueLen is the maximum length of values in human readable changes.
+	maxValueLen = 80
+)
+
+// ChangeType is the type of a change.
+type ChangeType string
+
+// Change types.
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+// Element is the type of element a change refers to.
+type Element string
+
+// Elements of generated objects.
+const (
+	// ElementField is any field that has no special meaning.
+	ElementField Element = "field"
+
+	// ElementResource is a composed resource template of a composition.
+	ElementResource Element = "resource"
+
+	// ElementPatch is a patch of a composed resource template.
+	ElementPatch Element = "patch"
+
+	// ElementVersion is a version of an XRD.
+	ElementVersion Element = "version"
+
+	// ElementProperty is a property of an XRD schema.
+	ElementProperty Element = "property"
+)
+
+// Change is a single change between two objects.
+type Change struct {
+	// Type is the type of change.
+	Type ChangeType `json:"type"`
+
+	// Element is the type of the changed element.
+	Element Element `json:"element"`
+
+	// Path identifies the changed element, e.g.
+	// resources[cluster-role].patches[0] or
+	// versions[v1alpha1].spec.parameters.name.
+	Path string `json:"path"`
+
+	// Field is the changed field of the element, if only parts of it
+	// changed.
+	Field string `json:"field,omitempty"`
+
+	// Old is the previous value.
+	Old interface{} `json:"old,omitempty"`
+
+	// New is the new value.
+	New interface{} `json:"new,omitempty"`
+}
+
+// String returns a human readable representation of this change.
+func (c Change) String() string {
+	target := c.Path
+	if c.Field != "" {
+		target = fmt.Sprintf("%s %s", c.Path, c.Field)
+	}
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("+ %s %s", c.Element, target)
+	case Removed:
+		return fmt.Sprintf("- %s %s", c.Element, target)
+	default:
+		return fmt.Sprintf("~ %s %s: %s -> %s", c.Element, target, formatValue(c.Old), formatValue(c.New))
+	}
+}
+
+// Objects returns the changes between the given objects. Compositions and
+// XRDs are compared by their structure, everything else field by field.
+func Objects(oldObj, newObj map[string]interface{}) []Change {
+	d := &differ{}
+	switch {
+	case kindOf(oldObj) == kindComposition && kindOf(newObj) == kindComposition:
+		d.composition(oldObj, newObj)
+	case kindOf(oldObj) == kindXRD && kindOf(newObj) == kindXRD:
+		d.xrd(oldObj, newObj)
+	default:
+		d.fields(ElementField, "", oldObj, newObj, nil)
+	}
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(c Change) {
+	d.changes = append(d.changes, c)
+}
+
+func (d *differ) composition(oldObj, newObj map[string]interface{}) {
+	d.fields(ElementField, "", oldObj, newObj, map[string]bool{"spec.resources": true})
+
+	oldRes := keyedList(get(oldObj, "spec", "resources"), "name")
+	newRes := keyedList(get(newObj, "spec", "resources"), "name")
+	for _, key := range unionKeys(oldRes, newRes) {
+		path := fmt.Sprintf("resources[%s]", key)
+		oldR, inOld := oldRes[key]
+		newR, inNew := newRes[key]
+		switch {
+		case !inOld:
+			d.add(Change{Type: Added, Element: ElementResource, Path: path, New: newR})
+		case !inNew:
+			d.add(Change{Type: Removed, Element: ElementResource, Path: path, Old: oldR})
+		default:
+			d.resource(path, asMap(oldR), asMap(newR))
+		}
+	}
+}
+
+func (d *differ) resource(path string, oldRes, newRes map[string]interface{}) {
+	d.fields(ElementResource, path, oldRes, newRes, map[string]bool{"patches": true})
+
+	oldPatches := asList(oldRes["patches"])
+	newPatches := asList(newRes["patches"])
+	for i := 0; i < len(oldPatches) || i < len(newPatches); i++ {
+		patchPath := fmt.Sprintf("%s.patches[%d]", path, i)
+		switch {
+		case i >= len(oldPatches):
+			d.add(Change{Type: Added, Element: ElementPatch, Path: patchPath, New: newPatches[i]})
+		case i >= len(newPatches):
+			d.add(Change{Type: Removed, Element: ElementPatch, Path: patchPath, Old: oldPatches[i]})
+		default:
+			d.fields(ElementPatch, patchPath, asMap(oldPatches[i]), asMap(newPatches[i]), nil)
+		}
+	}
+}
+
+func (d *differ) xrd(oldObj, newObj map[string]interface{}) {
+	d.fields(ElementField, "", oldObj, newObj, map[string]bool{"spec.versions": true})
+
+	oldVersions := keyedList(get(oldObj, "spec", "versions"), "name")
+	newVersions := keyedList(get(newObj, "spec", "versions"), "name")
+	for _, key := range unionKeys(oldVersions, newVersions) {
+		path := fmt.Sprintf("versions[%s]", key)
+		oldV, inOld := oldVersions[key]
+		newV, inNew := newVersions[key]
+		switch {
+		case !inOld:
+			d.add(Change{Type: Added, Element: ElementVersion, Path: path})
+		case !inNew:
+			d.add(Change{Type: Removed, Element: ElementVersion, Path: path})
+		default:
+			oldVM, newVM := asMap(oldV), asMap(newV)
+			d.fields(ElementVersion, path, oldVM, newVM, map[string]bool{"schema": true})
+			d.schema(path, asMap(get(oldVM, "schema", "openAPIV3Schema")), asMap(get(newVM, "schema", "openAPIV3Schema")))
+		}
+	}
+}
+
+// schema compares two OpenAPI schemas property by property.
+func (d *differ) schema(path string, oldSchema, newSchema map[string]interface{}) {
+	for _, attr := range unionKeys(oldSchema, newSchema) {
+		if attr == "properties" || attr == "items" {
+			continue
+		}
+		oldVal, newVal := oldSchema[attr], newSchema[attr]
+		if !reflect.DeepEqual(oldVal, newVal) {
+			d.add(Change{Type: changeType(oldVal, newVal), Element: ElementProperty, Path: path, Field: attr, Old: oldVal, New: newVal})
+		}
+	}
+
+	oldProps := asMap(oldSchema["properties"])
+	newProps := asMap(newSchema["properties"])
+	for _, name := range unionKeys(oldProps, newProps) {
+		propPath := joinPath(path, name)
+		oldP, inOld := oldProps[name]
+		newP, inNew := newProps[name]
+		switch {
+		case !inOld:
+			d.add(Change{Type: Added, Element: ElementProperty, Path: propPath})
+		case !inNew:
+			d.add(Change{Type: Removed, Element: ElementProperty, Path: propPath})
+		default:
+			d.schema(propPath, asMap(oldP), asMap(newP))
+		}
+	}
+
+	if oldSchema["items"] != nil || newSchema["items"] != nil {
+		d.schema(path+"[*]", asMap(oldSchema["items"]), asMap(newSchema["items"]))
+	}
+}
+
+// fields compares all fields of two objects recursively. Fields whose path
+// is in skip are ignored. Changes are reported for the given element.
+func (d *differ) fields(element Element, path string, oldVal, newVal interface{}, skip map[string]bool) {
+	d.fieldsAt(element, path, "", oldVal, newVal, skip)
+}
+
+func (d *differ) fieldsAt(element Element, path, field string, oldVal, newVal interface{}, skip map[string]bool) {
+	if skip[field] || reflect.DeepEqual(oldVal, newVal) {
+		return
+	}
+	oldMap, oldIsMap := oldVal.(map[string]interface{})
+	newMap, newIsMap := newVal.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		for _, key := range unionKeys(oldMap, newMap) {
+			d.fieldsAt(element, path, joinPath(field, key), oldMap[key], newMap[key], skip)
+		}
+		return
+	}
+	oldList, oldIsList := oldVal.([]interface{})
+	newList, newIsList := newVal.([]interface{})
+	if oldIsList && newIsList && len(oldList) == len(newList) {
+		for i := range oldList {
+			d.fieldsAt(element, path, fmt.Sprintf("%s[%d]", field, i), oldList[i], newList[i], skip)
+		}
+		return
+	}
+
+	if path == "" {
+		path, field = field, ""
+	}
+	d.add(Change{Type: changeType(oldVal, newVal), Element: element, Path: path, Field: field, Old: oldVal, New: newVal})
+}
+
+func changeType(oldVal, newVal interface{}) ChangeType {
+	switch {
+	case oldVal == nil:
+		return Added
+	case newVal == nil:
+		return Removed
+	default:
+		return Changed
+	}
+}
+
+func kindOf(obj map[string]interface{}) string {
+	kind, _ := obj["kind"].(string)
+	return kind
+}
+
+func get(obj map[string]interface{}, path ...string) interface{} {
+	var cur interface{} = obj
+	for _, p := range path {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[p]
+	}
+	return cur
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func asList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+// keyedList converts a list of objects into a map using the value of the
+// given key field. Items without key are keyed by their index.
+func keyedList(v interface{}, key string) map[string]interface{} {
+	res := map[string]interface{}{}
+	for i, item := range asList(v) {
+		name, ok := asMap(item)[key].(string)
+		if !ok || name == "" {
+			name = strconv.Itoa(i)
+		}
+		res[name] = item
+	}
+	return res
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, exists := a[k]; !exists {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, segment string) string {
+	if path == "" {
+		return segment
+	}
+	if strings.ContainsAny(segment, ".[]") {
+		return fmt.Sprintf("%s[%s]", path, segment)
+	}
+	return path + "." + segment
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "<none>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	if len(b) > maxValueLen {
+		return string(b[:maxValueLen]) + "..."
+	}
+	return string(b)
+}
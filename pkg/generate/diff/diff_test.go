@@ -0,0 +1,149 @@
+package diff
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/yaml"
+)
+
+func TestObjects(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		old    string
+		new    string
+		want   []string
+	}{
+		"Composition": {
+			reason: "Resources are compared by name and patches by index.",
+			old: `
+kind: Composition
+metadata: {name: example}
+spec:
+  resources:
+  - name: bucket
+    base: {kind: Bucket}
+    patches:
+    - fromFieldPath: spec.a
+  - name: removed
+`,
+			new: `
+kind: Composition
+metadata: {name: example}
+spec:
+  resources:
+  - name: added
+  - name: bucket
+    base: {kind: Bucket}
+    patches:
+    - fromFieldPath: spec.b
+    - fromFieldPath: spec.c
+`,
+			want: []string{
+				"+ resource resources[added]",
+				`~ patch resources[bucket].patches[0] fromFieldPath: "spec.a" -> "spec.b"`,
+				"+ patch resources[bucket].patches[1]",
+				"- resource resources[removed]",
+			},
+		},
+		"XRD": {
+			reason: "Versions are compared by name and schemas property by property.",
+			old: `
+kind: CompositeResourceDefinition
+spec:
+  versions:
+  - name: v1alpha1
+    schema:
+      openAPIV3Schema:
+        properties:
+          spec:
+            properties:
+              size: {type: string}
+  - name: v1alpha2
+`,
+			new: `
+kind: CompositeResourceDefinition
+spec:
+  versions:
+  - name: v1alpha1
+    schema:
+      openAPIV3Schema:
+        properties:
+          spec:
+            required: [size]
+            properties:
+              size: {type: integer}
+              region: {type: string}
+`,
+			want: []string{
+				"+ property versions[v1alpha1].spec required",
+				"+ property versions[v1alpha1].spec.region",
+				`~ property versions[v1alpha1].spec.size type: "string" -> "integer"`,
+				"- version versions[v1alpha2]",
+			},
+		},
+		"Other": {
+			reason: "Other objects are compared field by field.",
+			old:    "kind: ConfigMap\ndata: {a: \"1\"}\n",
+			new:    "kind: ConfigMap\ndata: {a: \"2\"}\n",
+			want:   []string{`~ field data.a: "1" -> "2"`},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			oldObj, newObj := map[string]interface{}{}, map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(tc.old), &oldObj); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tc.new), &newObj); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, c := range Objects(oldObj, newObj) {
+				got = append(got, c.String())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nObjects(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"same.yaml":    {Data: []byte("kind: A\n")},
+		"changed.yaml": {Data: []byte("kind: A\nvalue: 1\n")},
+		"stale.yaml":   {Data: []byte("kind: A\n")},
+	}
+	files := map[string][]byte{
+		"same.yaml":    []byte(`{"kind": "A"}`),
+		"changed.yaml": []byte("kind: A\nvalue: 2\n"),
+		"new.yaml":     []byte("kind: A\n"),
+	}
+	got, err := FS(fsys, files, func(string) bool { return true })
+	if err != nil {
+		t.Fatalf("FS(...): %v", err)
+	}
+	want := &Report{Files: []FileDiff{
+		{File: "changed.yaml", Type: Changed, Changes: []Change{{Type: Changed, Element: ElementField, Path: "value", Old: float64(1), New: float64(2)}}},
+		{File: "new.yaml", Type: Added},
+		{File: "stale.yaml", Type: Removed},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FS(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestFileFSIgnoredAnnotations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.yaml": {Data: []byte("kind: A\nmetadata:\n  annotations:\n    crossbuilder.mistermx.github.io/generator-version: v0.1.0\n")},
+	}
+	got, err := FileFS(fsys, "a.yaml", []byte("kind: A\nmetadata:\n  annotations:\n    crossbuilder.mistermx.github.io/generator-version: v0.2.0\n"))
+	if err != nil {
+		t.Fatalf("FileFS(...): %v", err)
+	}
+	if got != nil {
+		t.Errorf("FileFS(...): want no changes, got %v", got.Changes)
+	}
+}
@@ -0,0 +1,151 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+	"github.com/mistermx/crossbuilder/pkg/generate/verify"
+)
+
+const (
+	errReadFile     = "failed to read file"
+	errFmtParseFile = "failed to parse %s"
+	errFmtFormat    = "unknown diff format %q"
+)
+
+// Format is the output format of a Report.
+type Format string
+
+// Supported output formats.
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// FileDiff contains the changes of a single file.
+type FileDiff struct {
+	// File is the path of the file relative to the compared directory.
+	File string `json:"file"`
+
+	// Type is Added if the file does not exist yet, Removed if the file
+	// exists but has not been generated and Changed otherwise.
+	Type ChangeType `json:"type"`
+
+	// Changes are the changes of the objects in the file.
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Report contains the differences between generated files and a directory.
+type Report struct {
+	Files []FileDiff `json:"files"`
+}
+
+// Write writes this report in the given format.
+func (r *Report) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatText, "":
+		return r.writeText(w)
+	}
+	return errors.Errorf(errFmtFormat, format)
+}
+
+func (r *Report) writeText(w io.Writer) error {
+	if len(r.Files) == 0 {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+	for _, f := range r.Files {
+		if _, err := fmt.Fprintf(w, "%s (%s)\n", f.File, f.Type); err != nil {
+			return err
+		}
+		for _, c := range f.Changes {
+			if _, err := fmt.Fprintf(w, "  %s\n", c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Directory compares the given generated files with the files in dir.
+// The keys of files are paths relative to dir. Existing files for which
+// isGenerated returns true but that are not in files are reported as
+// removed.
+func Directory(dir string, files map[string][]byte, isGenerated func(relPath string) bool) (*Report, error) {
+	return FS(filesystem.NewOS(dir), files, isGenerated)
+}
+
+// FS works like Directory but compares with the files of fsys.
+func FS(fsys fs.FS, files map[string][]byte, isGenerated func(relPath string) bool) (*Report, error) {
+	report := &Report{}
+	for path, generated := range files {
+		fd, err := FileFS(fsys, path, generated)
+		if err != nil {
+			return nil, err
+		}
+		if fd != nil {
+			report.Files = append(report.Files, *fd)
+		}
+	}
+
+	existing, err := verify.ExistingFilesFS(fsys, isGenerated)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range existing {
+		if _, exists := files[path]; !exists {
+			report.Files = append(report.Files, FileDiff{File: path, Type: Removed})
+		}
+	}
+
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].File < report.Files[j].File
+	})
+	return report, nil
+}
+
+// File compares the generated content with the file at path. It returns nil
+// if there are no changes.
+func File(path string, generated []byte) (*FileDiff, error) {
+	return FileFS(filesystem.NewOS(filepath.Dir(path)), filepath.Base(path), generated)
+}
+
+// FileFS compares the generated content with the named file of fsys. It
+// returns nil if there are no changes. verify.IgnoredAnnotations are not
+// compared.
+func FileFS(fsys fs.FS, name string, generated []byte) (*FileDiff, error) {
+	existing, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &FileDiff{File: name, Type: Added}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errReadFile)
+	}
+
+	oldObj, newObj := map[string]interface{}{}, map[string]interface{}{}
+	if err := yaml.Unmarshal(existing, &oldObj); err != nil {
+		return nil, errors.Wrapf(err, errFmtParseFile, name)
+	}
+	if err := yaml.Unmarshal(generated, &newObj); err != nil {
+		return nil, errors.Wrapf(err, errFmtParseFile, name)
+	}
+	verify.RemoveIgnoredAnnotations(oldObj)
+	verify.RemoveIgnoredAnnotations(newObj)
+	changes := Objects(oldObj, newObj)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return &FileDiff{File: name, Type: Changed, Changes: changes}, nil
+}
@@ -0,0 +1,75 @@
+// Package filesystem provides the writable file systems generated files are
+// written to.
+package filesystem
+
+import (
+	"io/fs"
+	"path"
+)
+
+// FS is a writable file system. Like in io/fs, names are slash separated
+// paths relative to the root of the file system.
+type FS interface {
+	fs.ReadFileFS
+
+	// WriteFile writes data to the named file and creates all missing
+	// parent directories.
+	WriteFile(name string, data []byte) error
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+}
+
+// Sub returns the FS rooted at dir of fsys. Operations fail if dir is not a
+// valid path of fsys.
+func Sub(fsys FS, dir string) FS {
+	if dir == "." || dir == "" {
+		return fsys
+	}
+	return &subFS{fsys: fsys, dir: dir}
+}
+
+type subFS struct {
+	fsys FS
+	dir  string
+}
+
+// fullName returns the name of the given file in the parent file system.
+func (s *subFS) fullName(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(s.dir, name), nil
+}
+
+func (s *subFS) Open(name string) (fs.File, error) {
+	full, err := s.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fsys.Open(full)
+}
+
+func (s *subFS) ReadFile(name string) ([]byte, error) {
+	full, err := s.fullName("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return s.fsys.ReadFile(full)
+}
+
+func (s *subFS) WriteFile(name string, data []byte) error {
+	full, err := s.fullName("write", name)
+	if err != nil {
+		return err
+	}
+	return s.fsys.WriteFile(full, data)
+}
+
+func (s *subFS) Remove(name string) error {
+	full, err := s.fullName("remove", name)
+	if err != nil {
+		return err
+	}
+	return s.fsys.Remove(full)
+}
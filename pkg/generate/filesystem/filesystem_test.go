@@ -0,0 +1,99 @@
+package filesystem
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestFS(t *testing.T) {
+	cases := map[string]FS{
+		"Memory": NewMemory(),
+		"OS":     NewOS(t.TempDir()),
+		"Sub":    Sub(NewMemory(), "sub/dir"),
+	}
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := fsys.WriteFile("a/b.yaml", []byte("b")); err != nil {
+				t.Fatalf("WriteFile(...): %v", err)
+			}
+			if err := fsys.WriteFile("a/b.yaml", []byte("replaced")); err != nil {
+				t.Fatalf("WriteFile(...): %v", err)
+			}
+			got, err := fsys.ReadFile("a/b.yaml")
+			if err != nil {
+				t.Fatalf("ReadFile(...): %v", err)
+			}
+			if diff := cmp.Diff("replaced", string(got)); diff != "" {
+				t.Errorf("ReadFile(...): -want, +got:\n%s", diff)
+			}
+
+			files := []string{}
+			err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					files = append(files, path)
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatalf("WalkDir(...): %v", err)
+			}
+			if diff := cmp.Diff([]string{"a/b.yaml"}, files); diff != "" {
+				t.Errorf("WalkDir(...): temporary files must not remain: -want, +got:\n%s", diff)
+			}
+
+			if err := fsys.WriteFile("../escape.yaml", nil); !errors.Is(err, fs.ErrInvalid) {
+				t.Errorf("WriteFile(../escape.yaml): want fs.ErrInvalid, got %v", err)
+			}
+			if err := fsys.Remove("a/b.yaml"); err != nil {
+				t.Fatalf("Remove(...): %v", err)
+			}
+			if _, err := fsys.ReadFile("a/b.yaml"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("ReadFile(...) after Remove: want fs.ErrNotExist, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOSFileMode(t *testing.T) {
+	dir := t.TempDir()
+	mode := fs.FileMode(0666)
+
+	// os.WriteFile applies the umask of the process, so its result is the
+	// expected mode for the same requested mode.
+	if err := os.WriteFile(filepath.Join(dir, "reference"), nil, mode); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.Stat(filepath.Join(dir, "reference"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewOS(dir, WithFileMode(mode)).WriteFile("written", []byte("data")); err != nil {
+		t.Fatalf("WriteFile(...): %v", err)
+	}
+	got, err := os.Stat(filepath.Join(dir, "written"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode() != want.Mode() {
+		t.Errorf("WriteFile(...): want mode %v, got %v", want.Mode(), got.Mode())
+	}
+}
+
+func TestMemoryRemoveDirectory(t *testing.T) {
+	m := NewMemory()
+	if err := m.WriteFile("dir/file", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("dir"); err == nil {
+		t.Errorf("Remove(dir): want error for non-empty directory")
+	}
+	if err := m.WriteFile("dir", nil); err == nil {
+		t.Errorf("WriteFile(dir): want error for directory")
+	}
+}
@@ -0,0 +1,105 @@
+package filesystem
+
+import (
+	"io/fs"
+	"strings"
+	"sync"
+	"syscall"
+	"testing/fstest"
+)
+
+// NewMemory returns an empty in-memory FS.
+func NewMemory() *Memory {
+	return &Memory{
+		files: map[string][]byte{},
+	}
+}
+
+// Memory is an FS that keeps all files in memory. Directories exist
+// implicitly as long as they contain files. It is safe for concurrent use.
+type Memory struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// Open opens the named file or directory for reading.
+func (m *Memory) Open(name string) (fs.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshot := make(fstest.MapFS, len(m.files))
+	for f, data := range m.files {
+		snapshot[f] = &fstest.MapFile{Data: data, Mode: DefaultFileMode}
+	}
+	return snapshot.Open(name)
+}
+
+// ReadFile returns a copy of the content of the named file.
+func (m *Memory) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte{}, data...), nil
+}
+
+// WriteFile stores a copy of data as the named file.
+func (m *Memory) WriteFile(name string, data []byte) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isDir(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: syscall.EISDIR}
+	}
+	m.files[name] = append([]byte{}, data...)
+	return nil
+}
+
+// Remove removes the named file. Removing a directory fails as long as it
+// contains files.
+func (m *Memory) Remove(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; ok {
+		delete(m.files, name)
+		return nil
+	}
+	if m.isDir(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+}
+
+// Files returns a copy of all files keyed by their name.
+func (m *Memory) Files() map[string][]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	files := make(map[string][]byte, len(m.files))
+	for name, data := range m.files {
+		files[name] = append([]byte{}, data...)
+	}
+	return files
+}
+
+// isDir returns true if name is a directory that contains files.
+func (m *Memory) isDir(name string) bool {
+	if name == "." {
+		return len(m.files) > 0
+	}
+	prefix := name + "/"
+	for f := range m.files {
+		if strings.HasPrefix(f, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package filesystem
+
+import (
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultFileMode is the default mode of files written by OS.
+	DefaultFileMode fs.FileMode = 0664
+
+	// DefaultDirMode is the default mode of directories created by OS.
+	DefaultDirMode fs.FileMode = 0777
+
+	// maxTempAttempts is the number of temporary file names that are tried
+	// before giving up.
+	maxTempAttempts = 10000
+)
+
+// OSOption configures an OS file system.
+type OSOption func(o *OS)
+
+// WithFileMode sets the mode of written files. Defaults to DefaultFileMode.
+// Like for os.WriteFile, the umask of the process is applied.
+func WithFileMode(m fs.FileMode) OSOption {
+	return func(o *OS) {
+		o.fileMode = m
+	}
+}
+
+// WithDirMode sets the mode of created directories. Defaults to
+// DefaultDirMode.
+func WithDirMode(m fs.FileMode) OSOption {
+	return func(o *OS) {
+		o.dirMode = m
+	}
+}
+
+// NewOS returns an FS for the directory root of the local file system.
+// An empty root is the working directory.
+func NewOS(root string, opts ...OSOption) *OS {
+	if root == "" {
+		root = "."
+	}
+	o := &OS{
+		root:     root,
+		fileMode: DefaultFileMode,
+		dirMode:  DefaultDirMode,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// OS is an FS backed by a directory of the local file system. Files are
+// written atomically: the content is written to a temporary file in the
+// same directory that is renamed afterwards, so readers never see partially
+// written files.
+type OS struct {
+	root     string
+	fileMode fs.FileMode
+	dirMode  fs.FileMode
+}
+
+// path returns the path of the named file on the local file system.
+func (o *OS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(o.root, filepath.FromSlash(name)), nil
+}
+
+// Open opens the named file for reading.
+func (o *OS) Open(name string) (fs.File, error) {
+	p, err := o.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p) //nolint:gosec
+}
+
+// ReadFile reads the named file.
+func (o *OS) ReadFile(name string) ([]byte, error) {
+	p, err := o.path("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p) //nolint:gosec
+}
+
+// WriteFile atomically replaces the named file with data.
+func (o *OS) WriteFile(name string, data []byte) error {
+	p, err := o.path("write", name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, o.dirMode); err != nil {
+		return err
+	}
+	tmp, err := o.createTemp(dir, "."+filepath.Base(p)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck,gosec
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// createTemp creates a new file in dir whose name starts with prefix. Unlike
+// os.CreateTemp, the file is created with the file mode of o, so the umask
+// is applied just like for the file it replaces.
+func (o *OS) createTemp(dir, prefix string) (*os.File, error) {
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)) //nolint:gosec
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, o.fileMode)
+		if errors.Is(err, fs.ErrExist) && i < maxTempAttempts {
+			continue
+		}
+		return f, err
+	}
+}
+
+// Remove removes the named file or empty directory.
+func (o *OS) Remove(name string) error {
+	p, err := o.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
@@ -0,0 +1,145 @@
+// Package index maintains the index file that records which files of a
+// directory are owned by a crossbuilder generator. Owned files are removed
+// once they are no longer generated, all other files are never touched.
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+)
+
+const (
+	// FileName is the name of the file that lists all files in a directory
+	// that are owned by a generator.
+	FileName = ".crossbuilder-index"
+
+	indexHeader = "# Files generated by crossbuilder. Files listed here are removed once they are no longer generated."
+
+	errReadIndex  = "failed to read index file"
+	errWriteIndex = "failed to write index file"
+	errPruneFile  = "failed to remove stale file"
+)
+
+// Read returns the files listed in the index file of fsys. It returns false
+// if there is no index file.
+func Read(fsys fs.FS) ([]string, bool, error) {
+	b, err := fs.ReadFile(fsys, FileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrap(err, errReadIndex)
+	}
+
+	files := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		files = append(files, line)
+	}
+	return files, true, errors.Wrap(scanner.Err(), errReadIndex)
+}
+
+// Write writes the index file of fsys. The file is only written if
+// its content changes, so runs that generate the same files do not touch
+// it.
+func Write(fsys filesystem.FS, files []string) error {
+	sorted := append([]string{}, files...)
+	sort.Strings(sorted)
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(indexHeader + "\n")
+	for _, f := range sorted {
+		buf.WriteString(f + "\n")
+	}
+	existing, err := fs.ReadFile(fsys, FileName)
+	if err == nil && bytes.Equal(existing, buf.Bytes()) {
+		return nil
+	}
+	return errors.Wrap(fsys.WriteFile(FileName, buf.Bytes()), errWriteIndex)
+}
+
+// Prune removes all files listed in the index of fsys that are not in
+// keep and writes a new index containing keep.
+// Files that are not listed in the index are never removed.
+func Prune(fsys filesystem.FS, keep []string) error {
+	owned, _, err := Read(fsys)
+	if err != nil {
+		return err
+	}
+	kept := make(map[string]bool, len(keep))
+	for _, f := range keep {
+		kept[f] = true
+	}
+	for _, f := range owned {
+		if kept[f] {
+			continue
+		}
+		if err := fsys.Remove(f); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return errors.Wrap(err, errPruneFile)
+		}
+		removeEmptyParents(fsys, f)
+	}
+	return Write(fsys, keep)
+}
+
+// removeEmptyParents removes the parent directories of f as long as they
+// are empty.
+func removeEmptyParents(fsys filesystem.FS, f string) {
+	for parent := path.Dir(f); parent != "."; parent = path.Dir(parent) {
+		// Remove fails for directories that are not empty.
+		if err := fsys.Remove(parent); err != nil {
+			return
+		}
+	}
+}
+
+// Extend adds the given files to the index of fsys without removing
+// anything.
+func Extend(fsys filesystem.FS, files []string) error {
+	owned, _, err := Read(fsys)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(owned))
+	for _, f := range owned {
+		seen[f] = true
+	}
+	for _, f := range files {
+		if !seen[f] {
+			owned = append(owned, f)
+			seen[f] = true
+		}
+	}
+	return Write(fsys, owned)
+}
+
+// OwnedFilter returns a function that reports whether a file in fsys is
+// owned by a generator. If fsys has an index file, only the
+// files listed in it are owned. Otherwise all files for which couldOwn
+// returns true are considered owned.
+func OwnedFilter(fsys fs.FS, couldOwn func(path string) bool) (func(path string) bool, error) {
+	owned, hasIndex, err := Read(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if !hasIndex {
+		return couldOwn, nil
+	}
+	ownedSet := make(map[string]bool, len(owned))
+	for _, f := range owned {
+		ownedSet[f] = true
+	}
+	return func(path string) bool { return ownedSet[path] }, nil
+}
@@ -0,0 +1,83 @@
+package index
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+)
+
+func TestPruneFiles(t *testing.T) {
+	fsys := filesystem.NewMemory()
+	files := map[string]string{
+		"kept.yaml":         "kind: Composition\n",
+		"stale.yaml":        "kind: Composition\n",
+		"nested/stale.yaml": "kind: Composition\n",
+		"hand-written.yaml": "kind: Composition\n",
+	}
+	for name, data := range files {
+		if err := fsys.WriteFile(name, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Write(fsys, []string{"kept.yaml", "stale.yaml", "nested/stale.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Prune(fsys, []string{"kept.yaml", "new.yaml"}); err != nil {
+		t.Fatalf("Prune(...): %v", err)
+	}
+
+	want := []string{FileName, "hand-written.yaml", "kept.yaml"}
+	if diff := cmp.Diff(want, fileNames(fsys)); diff != "" {
+		t.Errorf("Prune(...): files: -want, +got:\n%s", diff)
+	}
+	owned, _, err := Read(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"kept.yaml", "new.yaml"}, owned); diff != "" {
+		t.Errorf("Prune(...): index: -want, +got:\n%s", diff)
+	}
+}
+
+// countingFS counts the writes of each file.
+type countingFS struct {
+	filesystem.FS
+	writes map[string]int
+}
+
+func (c *countingFS) WriteFile(name string, data []byte) error {
+	c.writes[name]++
+	return c.FS.WriteFile(name, data)
+}
+
+func TestWriteIndexUnchanged(t *testing.T) {
+	fsys := &countingFS{FS: filesystem.NewMemory(), writes: map[string]int{}}
+	if err := Write(fsys, []string{"b.yaml", "a.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(fsys, []string{"a.yaml", "b.yaml"}); err != nil {
+		t.Fatalf("Write(...): %v", err)
+	}
+	if got := fsys.writes[FileName]; got != 1 {
+		t.Errorf("Write(...): want index written once for the same files, got %d writes", got)
+	}
+	if err := Write(fsys, []string{"a.yaml"}); err != nil {
+		t.Fatalf("Write(...): %v", err)
+	}
+	if got := fsys.writes[FileName]; got != 2 {
+		t.Errorf("Write(...): want index written again for other files, got %d writes", got)
+	}
+}
+
+func fileNames(fsys *filesystem.Memory) []string {
+	names := []string{}
+	for name := range fsys.Files() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,143 @@
+// Package kustomize maintains kustomization files for directories of
+// generated manifests.
+package kustomize
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+	"github.com/mistermx/crossbuilder/pkg/generate/verify"
+)
+
+const (
+	errReadKustomization  = "failed to read kustomization"
+	errParseKustomization = "failed to parse kustomization"
+	errWriteKustomization = "failed to write kustomization"
+
+	// FileName is the name of the generated kustomization file.
+	FileName = "kustomization.yaml"
+
+	apiVersion = "kustomize.config.k8s.io/v1beta1"
+	kind       = "Kustomization"
+
+	keyAPIVersion   = "apiVersion"
+	keyKind         = "kind"
+	keyResources    = "resources"
+	keyCommonLabels = "commonLabels"
+	keyNamespace    = "namespace"
+)
+
+// Options configure the generated kustomization.
+type Options struct {
+	// CommonLabels are added to all resources. The commonLabels of an
+	// existing kustomization are kept if empty.
+	CommonLabels map[string]string
+}
+
+// IsKustomizationFile returns true if path is a kustomization file.
+func IsKustomizationFile(path string) bool {
+	switch filepath.Base(path) {
+	case "kustomization.yaml", "kustomization.yml", "Kustomization":
+		return true
+	}
+	return false
+}
+
+// IsResourceFile returns true if path is a YAML file that is not a
+// kustomization file.
+func IsResourceFile(path string) bool {
+	return verify.IsYAMLFile(path) && !IsKustomizationFile(path)
+}
+
+// Update creates or updates the kustomization file in dir so that it lists
+// the given files, which have been generated into dir, as resources in
+// lexical order. Other resources of an existing kustomization, like
+// directories, remote targets or hand-written files, are kept in front of
+// them. Listed local files that no longer exist are removed.
+//
+// Other fields of an existing kustomization are kept, except for namespace:
+// XRDs and compositions are cluster scoped and Kustomize does not know that,
+// so it would add a namespace to them.
+func Update(dir string, files []string, opts Options) error {
+	return UpdateFS(filesystem.NewOS(dir), files, opts)
+}
+
+// UpdateFS works like Update but maintains the kustomization in the root of
+// fsys.
+func UpdateFS(fsys filesystem.FS, files []string, opts Options) error {
+	k := map[string]interface{}{}
+	existing, err := fsys.ReadFile(FileName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Wrap(err, errReadKustomization)
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(existing, &k); err != nil {
+			return errors.Wrap(err, errParseKustomization)
+		}
+		if k == nil {
+			k = map[string]interface{}{}
+		}
+	}
+
+	generated := []string{}
+	isGenerated := map[string]bool{}
+	for _, f := range files {
+		if IsResourceFile(f) && !isGenerated[f] {
+			generated = append(generated, f)
+			isGenerated[f] = true
+		}
+	}
+	sort.Strings(generated)
+
+	resources := []interface{}{}
+	if prev, ok := k[keyResources].([]interface{}); ok {
+		for _, r := range prev {
+			if s, ok := r.(string); ok && isLocalFile(s) {
+				// Generated files are added in order below.
+				name := path.Clean(s)
+				if isGenerated[name] || !exists(fsys, name) {
+					continue
+				}
+			}
+			resources = append(resources, r)
+		}
+	}
+	for _, f := range generated {
+		resources = append(resources, f)
+	}
+	k[keyAPIVersion] = apiVersion
+	k[keyKind] = kind
+	k[keyResources] = resources
+	if len(opts.CommonLabels) > 0 {
+		k[keyCommonLabels] = opts.CommonLabels
+	}
+	delete(k, keyNamespace)
+	b, err := yaml.Marshal(k)
+	if err != nil {
+		return errors.Wrap(err, errWriteKustomization)
+	}
+	return errors.Wrap(fsys.WriteFile(FileName, b), errWriteKustomization)
+}
+
+// isLocalFile returns true if the resource entry refers to a YAML file in
+// the kustomization directory or one of its subdirectories.
+func isLocalFile(resource string) bool {
+	if strings.Contains(resource, "://") || path.IsAbs(resource) {
+		return false
+	}
+	clean := path.Clean(resource)
+	return clean != ".." && !strings.HasPrefix(clean, "../") && verify.IsYAMLFile(clean)
+}
+
+// exists returns true if name is a file in fsys.
+func exists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
@@ -0,0 +1,87 @@
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+)
+
+func TestUpdateFS(t *testing.T) {
+	fsys := filesystem.NewMemory()
+	files := map[string]string{
+		FileName: `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: default
+resources:
+- https://example.org/remote.yaml
+- ../base
+- deleted.yaml
+- hand-written.yaml
+- a.yaml
+patches:
+- path: patch.yaml
+`,
+		"b.yaml":                     "kind: Composition\n",
+		"a.yaml":                     "kind: Composition\n",
+		"nested/c.yml":               "kind: Composition\n",
+		"hand-written.yaml":          "kind: Composition\n",
+		"other.yaml":                 "kind: Composition\n",
+		"README.md":                  "# docs\n",
+		"overlay/kustomization.yaml": "kind: Kustomization\n",
+		"overlay/d.yaml":             "kind: Composition\n",
+	}
+	for name, content := range files {
+		if err := fsys.WriteFile(name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generated := []string{"b.yaml", "a.yaml", "nested/c.yml", "README.md"}
+	if err := UpdateFS(fsys, generated, Options{CommonLabels: map[string]string{"team": "platform"}}); err != nil {
+		t.Fatalf("UpdateFS(...): %v", err)
+	}
+
+	b, err := fsys.ReadFile(FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"resources": []interface{}{
+			"https://example.org/remote.yaml",
+			"../base",
+			"hand-written.yaml",
+			"a.yaml",
+			"b.yaml",
+			"nested/c.yml",
+		},
+		"patches":      []interface{}{map[string]interface{}{"path": "patch.yaml"}},
+		"commonLabels": map[string]interface{}{"team": "platform"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("UpdateFS(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestUpdateFSEmpty(t *testing.T) {
+	fsys := filesystem.NewMemory()
+	if err := UpdateFS(fsys, nil, Options{}); err != nil {
+		t.Fatalf("UpdateFS(...): %v", err)
+	}
+	b, err := fsys.ReadFile(FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources: []\n"
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Errorf("UpdateFS(...): -want, +got:\n%s", diff)
+	}
+}
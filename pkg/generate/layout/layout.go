@@ -0,0 +1,115 @@
+// Package layout renders the relative paths generated files are written to
+// from user defined templates.
+package layout
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errParseTemplate    = "failed to parse file name template"
+	errExecuteTemplate  = "failed to execute file name template"
+	errFmtInvalidPath   = "file name template produced invalid path %q"
+	errFmtPathNotInDir  = "file name template produced path %q outside of the output directory"
+	errFmtPathNotLocal  = "file name template produced absolute path %q"
+	errFmtPathEmptyPart = "file name template produced path %q with empty segment"
+	errFmtPathEmptyName = "file name template produced path %q with empty file name"
+)
+
+// Data is passed to file name templates.
+type Data struct {
+	// Group is the API group of the composite resource.
+	Group string
+
+	// Version is the API version of the composite resource.
+	Version string
+
+	// Kind is the kind of the composite resource.
+	Kind string
+
+	// Plural is the plural name of the composite resource. Only set for
+	// XRDs.
+	Plural string
+
+	// Name is the name of the generated object, i.e. of the composition or
+	// XRD.
+	Name string
+
+	// Labels are the labels of the generated object.
+	Labels map[string]string
+
+	// Tags are the tags the builder of a composition has been registered
+	// with. Only set for compositions.
+	Tags []string
+}
+
+// funcs are the functions available in file name templates.
+var funcs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+}
+
+// Template renders relative file paths.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses a text/template for file paths, e.g.
+//
+//	{{ .Kind | lower }}/{{ index .Labels "variant" }}.yaml
+//
+// In addition to the builtin functions the template can use lower, upper,
+// join and replace.
+func Parse(text string) (*Template, error) {
+	t, err := template.New("filename").Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseTemplate)
+	}
+	return &Template{tmpl: t}, nil
+}
+
+// Must is like Parse but panics on errors.
+func Must(text string) *Template {
+	t, err := Parse(text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Execute renders the path for the given data. The result is a clean,
+// slash separated path that is relative to and inside of the output
+// directory.
+func (t *Template) Execute(data Data) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := t.tmpl.Execute(buf, data); err != nil {
+		return "", errors.Wrap(err, errExecuteTemplate)
+	}
+	p := strings.TrimSpace(buf.String())
+	switch {
+	case p == "" || strings.HasSuffix(p, "/"):
+		return "", errors.Errorf(errFmtInvalidPath, p)
+	case path.IsAbs(p):
+		return "", errors.Errorf(errFmtPathNotLocal, p)
+	case strings.Contains(p, "//"):
+		return "", errors.Errorf(errFmtPathEmptyPart, p)
+	}
+	clean := path.Clean(p)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errors.Errorf(errFmtPathNotInDir, p)
+	}
+	if strings.HasPrefix(path.Base(clean), ".") {
+		// Usually caused by a missing label or tag.
+		return "", errors.Errorf(errFmtPathEmptyName, p)
+	}
+	return clean, nil
+}
@@ -0,0 +1,80 @@
+package layout
+
+import (
+	"testing"
+)
+
+func TestTemplateExecute(t *testing.T) {
+	data := Data{
+		Group:   "aws.example.org",
+		Version: "v1alpha1",
+		Kind:    "XBucket",
+		Name:    "xbucket-s3",
+		Labels:  map[string]string{"variant": "s3"},
+		Tags:    []string{"aws", "storage"},
+	}
+	cases := map[string]struct {
+		reason   string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		"Functions": {
+			reason:   "Templates can use the label map and the template functions.",
+			template: `{{ .Kind | lower }}/{{ index .Labels "variant" }}.yaml`,
+			want:     "xbucket/s3.yaml",
+		},
+		"Join": {
+			reason:   "Tags can be joined.",
+			template: `{{ join .Tags "-" }}/{{ replace "." "_" .Group }}.yaml`,
+			want:     "aws-storage/aws_example_org.yaml",
+		},
+		"Clean": {
+			reason:   "Paths are cleaned.",
+			template: `./{{ .Version }}/../{{ .Name }}.yaml`,
+			want:     "xbucket-s3.yaml",
+		},
+		"MissingLabel": {
+			reason:   "A missing label must not result in a hidden file.",
+			template: `{{ index .Labels "missing" }}.yaml`,
+			wantErr:  true,
+		},
+		"OutsideDirectory": {
+			reason:   "Paths must not leave the output directory.",
+			template: `../{{ .Name }}.yaml`,
+			wantErr:  true,
+		},
+		"Absolute": {
+			reason:   "Paths must be relative.",
+			template: `/{{ .Name }}.yaml`,
+			wantErr:  true,
+		},
+		"EmptySegment": {
+			reason:   "Paths must not have empty segments.",
+			template: `{{ index .Labels "missing" }}//{{ .Name }}.yaml`,
+			wantErr:  true,
+		},
+		"Directory": {
+			reason:   "Paths must name a file.",
+			template: `{{ .Kind }}/`,
+			wantErr:  true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Must(tc.template).Execute(data)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("\n%s\nExecute(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("\n%s\nExecute(...): want %q, got %q", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse("{{ .Kind"); err == nil {
+		t.Errorf("Parse(...): want error for invalid template")
+	}
+}
@@ -0,0 +1,144 @@
+// Package provenance records where generated objects come from and detects
+// objects that have been edited after they have been generated.
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"runtime/debug"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/annotations"
+)
+
+const (
+	// AnnotationPrefix is the prefix of all provenance annotations.
+	AnnotationPrefix = annotations.Prefix
+
+	// AnnotationGeneratorVersion is the version of crossbuilder that
+	// generated the object. It is one of verify.IgnoredAnnotations, so
+	// upgrading crossbuilder does not make generated files out of date.
+	AnnotationGeneratorVersion = annotations.GeneratorVersion
+
+	// AnnotationSource is the Go type the object has been generated from,
+	// i.e. the composition builder or the API type of an XRD, in the form
+	// <package path>.<type name>.
+	AnnotationSource = annotations.Source
+
+	// AnnotationSpecHash is the hash of the spec of the object at the time
+	// it has been generated.
+	AnnotationSpecHash = annotations.SpecHash
+
+	// DefaultHeader is the default header of generated files.
+	DefaultHeader = "Code generated by crossbuilder. DO NOT EDIT."
+
+	modulePath = "github.com/mistermx/crossbuilder"
+
+	hashPrefix = "sha256:"
+
+	errHashSpec = "failed to hash spec"
+)
+
+// GeneratorVersion returns the version of the crossbuilder module the
+// running binary has been built with or (devel) if it is unknown.
+func GeneratorVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		if dep.Version != "" {
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
+
+// TypeName returns the name of the Go type of v in the form
+// <package path>.<type name>. Pointers are dereferenced.
+func TypeName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	if t.PkgPath() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// SpecHash returns a stable hash of the given spec. The spec is hashed in
+// its canonical JSON form, so the hash of a typed spec equals the hash of
+// the same spec read from YAML or JSON.
+func SpecHash(spec interface{}) (string, error) {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return "", errors.Wrap(err, errHashSpec)
+	}
+	// Round trip through an untyped value to sort all keys.
+	var untyped interface{}
+	if err := json.Unmarshal(raw, &untyped); err != nil {
+		return "", errors.Wrap(err, errHashSpec)
+	}
+	canonical, err := json.Marshal(untyped)
+	if err != nil {
+		return "", errors.Wrap(err, errHashSpec)
+	}
+	sum := sha256.Sum256(canonical)
+	return hashPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+// Annotate sets the provenance annotations of obj. spec must be the spec
+// of obj in its final form, since later changes invalidate the hash.
+func Annotate(obj metav1.Object, source string, spec interface{}) error {
+	hash, err := SpecHash(spec)
+	if err != nil {
+		return err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AnnotationGeneratorVersion] = GeneratorVersion()
+	if source != "" {
+		annotations[AnnotationSource] = source
+	}
+	annotations[AnnotationSpecHash] = hash
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// Header returns text as YAML comment followed by an empty line. Every line
+// of text is commented out. An empty text results in an empty header.
+func Header(text string) string {
+	if text == "" {
+		return ""
+	}
+	b := &strings.Builder{}
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line == "" {
+			b.WriteString("#\n")
+			continue
+		}
+		b.WriteString("# " + line + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
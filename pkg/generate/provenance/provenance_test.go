@@ -0,0 +1,94 @@
+package provenance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type testSpec struct {
+	B string `json:"b"`
+	A int    `json:"a"`
+}
+
+func TestSpecHash(t *testing.T) {
+	typed, err := SpecHash(testSpec{A: 1, B: "x"})
+	if err != nil {
+		t.Fatalf("SpecHash(...): %v", err)
+	}
+	untyped, err := SpecHash(map[string]interface{}{"a": 1, "b": "x"})
+	if err != nil {
+		t.Fatalf("SpecHash(...): %v", err)
+	}
+	if typed != untyped {
+		t.Errorf("SpecHash(...): typed %q and untyped %q spec differ", typed, untyped)
+	}
+	if !strings.HasPrefix(typed, hashPrefix) {
+		t.Errorf("SpecHash(...): want prefix %q, got %q", hashPrefix, typed)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAnnotations(map[string]string{"keep": "me"})
+	spec := testSpec{A: 1}
+	if err := Annotate(obj, TypeName(&spec), spec); err != nil {
+		t.Fatalf("Annotate(...): %v", err)
+	}
+	hash, _ := SpecHash(spec)
+	want := map[string]string{
+		"keep":                     "me",
+		AnnotationGeneratorVersion: GeneratorVersion(),
+		AnnotationSource:           "github.com/mistermx/crossbuilder/pkg/generate/provenance.testSpec",
+		AnnotationSpecHash:         hash,
+	}
+	if diff := cmp.Diff(want, obj.GetAnnotations()); diff != "" {
+		t.Errorf("Annotate(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	want := "# first\n#\n# second\n\n"
+	if diff := cmp.Diff(want, Header("first\n\nsecond\n")); diff != "" {
+		t.Errorf("Header(...): -want, +got:\n%s", diff)
+	}
+	if got := Header(""); got != "" {
+		t.Errorf("Header(\"\"): want empty header, got %q", got)
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	hash, _ := SpecHash(map[string]interface{}{"a": 1})
+	data := `kind: A
+metadata:
+  name: unchanged
+  annotations:
+    ` + AnnotationSpecHash + `: ` + hash + `
+spec:
+  a: 1
+---
+kind: A
+metadata:
+  name: edited
+  annotations:
+    ` + AnnotationSpecHash + `: ` + hash + `
+spec:
+  a: 2
+---
+kind: A
+metadata:
+  name: unannotated
+spec:
+  a: 3
+`
+	got, err := Documents("a.yaml", []byte(data))
+	if err != nil {
+		t.Fatalf("Documents(...): %v", err)
+	}
+	want := []Edit{{File: "a.yaml", Kind: "A", Name: "edited"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Documents(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,129 @@
+package provenance
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/kustomize"
+	"github.com/mistermx/crossbuilder/pkg/generate/verify"
+)
+
+const (
+	errFmtReadFile  = "failed to read %s"
+	errFmtParseFile = "failed to parse %s"
+)
+
+// Edit is a generated object whose spec does not match its hash anymore.
+type Edit struct {
+	// File is the path of the file that contains the object.
+	File string
+
+	// Kind is the kind of the object.
+	Kind string
+
+	// Name is the name of the object.
+	Name string
+}
+
+func (e Edit) String() string {
+	return fmt.Sprintf("%s: %s %s", e.File, e.Kind, e.Name)
+}
+
+// Result contains the generated objects that have been edited.
+type Result struct {
+	Edited []Edit
+}
+
+// Err returns an *Error for this result if any object has been edited.
+func (r *Result) Err() error {
+	if len(r.Edited) == 0 {
+		return nil
+	}
+	return &Error{Result: *r}
+}
+
+// Error is returned if generated objects have been edited.
+type Error struct {
+	Result Result
+}
+
+func (e *Error) Error() string {
+	b := &strings.Builder{}
+	b.WriteString("generated objects have been edited:")
+	for _, edit := range e.Result.Edited {
+		fmt.Fprintf(b, "\n  %s", edit)
+	}
+	return b.String()
+}
+
+// Directory recomputes the spec hash of all objects in the YAML files of
+// dir and its subdirectories that have a spec hash annotation and reports
+// those whose spec does not match it. Objects without the annotation are
+// ignored.
+func Directory(dir string) (*Result, error) {
+	files, err := verify.ExistingFiles(dir, kustomize.IsResourceFile)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtReadFile, f)
+		}
+		edits, err := Documents(f, data)
+		if err != nil {
+			return nil, err
+		}
+		res.Edited = append(res.Edited, edits...)
+	}
+	return res, nil
+}
+
+// Documents recomputes the spec hash of all objects in the given YAML
+// stream that have a spec hash annotation and returns those whose spec does
+// not match it. file is only used to identify the objects.
+func Documents(file string, data []byte) ([]Edit, error) {
+	edits := []Edit{}
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return edits, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtParseFile, file)
+		}
+		obj := struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name        string            `json:"name"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+			Spec interface{} `json:"spec"`
+		}{}
+		if err := yaml.Unmarshal(doc, &obj); err != nil {
+			return nil, errors.Wrapf(err, errFmtParseFile, file)
+		}
+		expected, ok := obj.Metadata.Annotations[AnnotationSpecHash]
+		if !ok {
+			continue
+		}
+		actual, err := SpecHash(obj.Spec)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtParseFile, file)
+		}
+		if actual != expected {
+			edits = append(edits, Edit{File: file, Kind: obj.Kind, Name: obj.Metadata.Name})
+		}
+	}
+}
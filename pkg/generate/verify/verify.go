@@ -0,0 +1,197 @@
+package verify
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/annotations"
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+)
+
+const (
+	errReadFile      = "failed to read file"
+	errReadDirectory = "failed to read directory"
+	errParseFmt      = "failed to parse %s"
+)
+
+// IgnoredAnnotations are annotations that are ignored when files are
+// compared. Their values depend on the generator binary rather than on the
+// source, like the generator version, so comparing them
+// would report every file as changed after a crossbuilder upgrade.
+var IgnoredAnnotations = map[string]bool{
+	annotations.GeneratorVersion: true,
+}
+
+// Result contains the files that do not match the generated output.
+type Result struct {
+	// Differ are files whose content differs from the generated one.
+	Differ []string
+
+	// Missing are generated files that do not exist.
+	Missing []string
+
+	// Extra are files that exist but have not been generated.
+	Extra []string
+}
+
+// Empty returns true if all files are up to date.
+func (r *Result) Empty() bool {
+	return len(r.Differ) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// Err returns an *Error for this result if there are any stale files.
+func (r *Result) Err() error {
+	if r.Empty() {
+		return nil
+	}
+	return &Error{Result: *r}
+}
+
+// Error is returned if generated files are out of date.
+type Error struct {
+	Result Result
+}
+
+func (e *Error) Error() string {
+	b := &strings.Builder{}
+	b.WriteString("generated files are out of date:")
+	for _, f := range e.Result.Differ {
+		fmt.Fprintf(b, "\n  differs: %s", f)
+	}
+	for _, f := range e.Result.Missing {
+		fmt.Fprintf(b, "\n  missing: %s", f)
+	}
+	for _, f := range e.Result.Extra {
+		fmt.Fprintf(b, "\n  extra:   %s", f)
+	}
+	return b.String()
+}
+
+// Directory compares the given generated files with the files in dir.
+// The keys of files are paths relative to dir. Existing files for which
+// isGenerated returns true but that are not in files are reported as extra.
+// Files are compared semantically, so formatting and field order do not
+// matter.
+func Directory(dir string, files map[string][]byte, isGenerated func(relPath string) bool) (*Result, error) {
+	return FS(filesystem.NewOS(dir), files, isGenerated)
+}
+
+// FS works like Directory but compares with the files of fsys.
+func FS(fsys fs.FS, files map[string][]byte, isGenerated func(relPath string) bool) (*Result, error) {
+	res := &Result{}
+	for path, generated := range files {
+		existing, err := fs.ReadFile(fsys, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			res.Missing = append(res.Missing, path)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, errReadFile)
+		}
+		equal, err := Equal(generated, existing)
+		if err != nil {
+			return nil, errors.Wrapf(err, errParseFmt, path)
+		}
+		if !equal {
+			res.Differ = append(res.Differ, path)
+		}
+	}
+
+	existing, err := ExistingFilesFS(fsys, isGenerated)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range existing {
+		if _, exists := files[path]; !exists {
+			res.Extra = append(res.Extra, path)
+		}
+	}
+
+	sort.Strings(res.Differ)
+	sort.Strings(res.Missing)
+	sort.Strings(res.Extra)
+	return res, nil
+}
+
+// ExistingFiles returns the paths of all files in dir, relative to dir, for
+// which isGenerated returns true. A missing directory is treated as empty.
+func ExistingFiles(dir string, isGenerated func(relPath string) bool) ([]string, error) {
+	return ExistingFilesFS(filesystem.NewOS(dir), isGenerated)
+}
+
+// ExistingFilesFS works like ExistingFiles but lists the files of fsys.
+func ExistingFilesFS(fsys fs.FS, isGenerated func(relPath string) bool) ([]string, error) {
+	files := []string{}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isGenerated(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Wrap(err, errReadDirectory)
+	}
+	return files, nil
+}
+
+// Equal returns true if the given YAML or JSON documents are semantically
+// equal. IgnoredAnnotations are not compared.
+func Equal(a, b []byte) (bool, error) {
+	var objA, objB interface{}
+	if err := yaml.Unmarshal(a, &objA); err != nil {
+		return false, err
+	}
+	if err := yaml.Unmarshal(b, &objB); err != nil {
+		return false, err
+	}
+	RemoveIgnoredAnnotations(objA)
+	RemoveIgnoredAnnotations(objB)
+	return reflect.DeepEqual(objA, objB), nil
+}
+
+// RemoveIgnoredAnnotations removes all IgnoredAnnotations from the given
+// unstructured object and the items of lists. Annotation maps that become
+// empty are removed as well.
+func RemoveIgnoredAnnotations(obj interface{}) {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if items, ok := m["items"].([]interface{}); ok {
+		for _, item := range items {
+			RemoveIgnoredAnnotations(item)
+		}
+	}
+	metadata, ok := m["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key := range annotations {
+		if IgnoredAnnotations[key] {
+			delete(annotations, key)
+		}
+	}
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+}
+
+// IsYAMLFile returns true if path has a YAML file extension.
+func IsYAMLFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,110 @@
+package verify
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"same.yaml":       {Data: []byte("kind: A\nmetadata:\n  name: same\n")},
+		"reordered.yaml":  {Data: []byte("metadata: {name: reordered}\nkind: A\n")},
+		"changed.yaml":    {Data: []byte("kind: A\nspec:\n  replicas: 1\n")},
+		"stale.yaml":      {Data: []byte("kind: A\n")},
+		"README.md":       {Data: []byte("# Hand-written\n")},
+		"nested/new.yaml": {Data: []byte("kind: B\n")},
+	}
+	files := map[string][]byte{
+		"same.yaml":       []byte("kind: A\nmetadata:\n  name: same\n"),
+		"reordered.yaml":  []byte(`{"kind": "A", "metadata": {"name": "reordered"}}`),
+		"changed.yaml":    []byte("kind: A\nspec:\n  replicas: 2\n"),
+		"missing.yaml":    []byte("kind: A\n"),
+		"nested/new.yaml": []byte("kind: B\n"),
+	}
+
+	got, err := FS(fsys, files, IsYAMLFile)
+	if err != nil {
+		t.Fatalf("FS(...): %v", err)
+	}
+	want := &Result{
+		Differ:  []string{"changed.yaml"},
+		Missing: []string{"missing.yaml"},
+		Extra:   []string{"stale.yaml"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FS(...): -want, +got:\n%s", diff)
+	}
+	if got.Err() == nil {
+		t.Errorf("Err(): want error for stale files")
+	}
+}
+
+func TestFSUpToDate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.yaml": {Data: []byte("kind: A\n")},
+	}
+	got, err := FS(fsys, map[string][]byte{"a.yaml": []byte("kind: A")}, IsYAMLFile)
+	if err != nil {
+		t.Fatalf("FS(...): %v", err)
+	}
+	if err := got.Err(); err != nil {
+		t.Errorf("Err(): want nil, got %v", err)
+	}
+}
+
+func TestExistingFilesMissingDirectory(t *testing.T) {
+	got, err := ExistingFiles(t.TempDir()+"/missing", IsYAMLFile)
+	if err != nil {
+		t.Fatalf("ExistingFiles(...): %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("ExistingFiles(...): want no files, got %v", got)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		a      string
+		b      string
+		want   bool
+	}{
+		"GeneratorVersion": {
+			reason: "Different generator versions must not make files differ.",
+			a:      "kind: A\nmetadata:\n  annotations:\n    crossbuilder.mistermx.github.io/generator-version: v0.1.0\n",
+			b:      "kind: A\nmetadata:\n  annotations:\n    crossbuilder.mistermx.github.io/generator-version: v0.2.0\n",
+			want:   true,
+		},
+		"MissingGeneratorVersion": {
+			reason: "A missing generator version must not make files differ.",
+			a:      "kind: A\nmetadata:\n  annotations:\n    crossbuilder.mistermx.github.io/generator-version: v0.1.0\n",
+			b:      "kind: A\nmetadata: {}\n",
+			want:   true,
+		},
+		"ListItems": {
+			reason: "Annotations of list items are ignored as well.",
+			a:      "kind: List\nitems:\n- metadata:\n    annotations:\n      crossbuilder.mistermx.github.io/generator-version: v0.1.0\n",
+			b:      "kind: List\nitems:\n- metadata: {}\n",
+			want:   true,
+		},
+		"OtherAnnotation": {
+			reason: "All other annotations are compared.",
+			a:      "kind: A\nmetadata:\n  annotations:\n    crossbuilder.mistermx.github.io/spec-hash: a\n",
+			b:      "kind: A\nmetadata:\n  annotations:\n    crossbuilder.mistermx.github.io/spec-hash: b\n",
+			want:   false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Equal([]byte(tc.a), []byte(tc.b))
+			if err != nil {
+				t.Fatalf("Equal(...): %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("\n%s\nEqual(...): want %t, got %t", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
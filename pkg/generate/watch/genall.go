@@ -0,0 +1,182 @@
+package watch
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+const (
+	errGenerators    = "not all generators ran successfully"
+	errReloadRoots   = "failed to reload packages"
+	errFindModuleDir = "failed to find module directory"
+
+	goModFile = "go.mod"
+)
+
+// Generators runs the generators of rt once and then every time one of its
+// root packages or a package of the current module they import changes,
+// until ctx is done.
+//
+// Packages are cached between runs. Only the roots that are affected by a
+// change are loaded again.
+func Generators(ctx context.Context, rt *genall.Runtime, out io.Writer) error {
+	modDir, err := moduleDir()
+	if err != nil {
+		return err
+	}
+	rt.ErrorWriter = out
+	g := &genallWatcher{
+		rt:        rt,
+		modDir:    modDir,
+		numErrors: map[*loader.Package]int{},
+	}
+	g.check(rt.Roots)
+	return Run(ctx, out, g.run)
+}
+
+type genallWatcher struct {
+	rt     *genall.Runtime
+	modDir string
+
+	// numErrors is the number of errors of each root after loading and
+	// type checking. Errors that have been added by generators are removed
+	// before each run, so they are not reported twice.
+	numErrors map[*loader.Package]int
+}
+
+func (g *genallWatcher) run(changed []string) ([]string, error) {
+	if changed != nil {
+		if err := g.reload(changed); err != nil {
+			return nil, err
+		}
+	}
+	for _, root := range g.rt.Roots {
+		root.Errors = root.Errors[:g.numErrors[root]]
+	}
+	var err error
+	if g.rt.Run() {
+		err = errors.New(errGenerators)
+	}
+	return g.dirs(), err
+}
+
+// reload loads all roots that are affected by changes in the given
+// directories again.
+func (g *genallWatcher) reload(changed []string) error {
+	changedDirs := make(map[string]bool, len(changed))
+	for _, dir := range changed {
+		changedDirs[dir] = true
+	}
+	affected := []string{}
+	for _, root := range g.rt.Roots {
+		for dir := range g.localDirs(root) {
+			if changedDirs[dir] {
+				affected = append(affected, root.PkgPath)
+				break
+			}
+		}
+	}
+	if len(affected) == 0 {
+		return nil
+	}
+
+	reloaded, err := loader.LoadRoots(affected...)
+	if err != nil {
+		return errors.Wrap(err, errReloadRoots)
+	}
+	byPath := make(map[string]*loader.Package, len(reloaded))
+	for _, pkg := range reloaded {
+		byPath[pkg.PkgPath] = pkg
+	}
+	for i, root := range g.rt.Roots {
+		if pkg, ok := byPath[root.PkgPath]; ok {
+			delete(g.numErrors, root)
+			g.rt.Roots[i] = pkg
+		}
+	}
+	g.check(reloaded)
+	return nil
+}
+
+// check type checks the given roots and records their number of errors.
+func (g *genallWatcher) check(roots []*loader.Package) {
+	for _, root := range roots {
+		if g.rt.Checker != nil {
+			g.rt.Checker.Check(root)
+		} else {
+			root.NeedTypesInfo()
+		}
+		g.numErrors[root] = len(root.Errors)
+	}
+}
+
+// dirs returns the directories of all roots and the packages of the
+// current module they import.
+func (g *genallWatcher) dirs() []string {
+	all := map[string]bool{}
+	for _, root := range g.rt.Roots {
+		for dir := range g.localDirs(root) {
+			all[dir] = true
+		}
+	}
+	dirs := make([]string, 0, len(all))
+	for dir := range all {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// localDirs returns the directories of pkg and all packages of the current
+// module it imports directly or indirectly.
+func (g *genallWatcher) localDirs(pkg *loader.Package) map[string]bool {
+	dirs := map[string]bool{}
+	visited := map[*loader.Package]bool{}
+	var visit func(p *loader.Package)
+	visit = func(p *loader.Package) {
+		if visited[p] || len(p.GoFiles) == 0 {
+			return
+		}
+		visited[p] = true
+		dir := filepath.Dir(p.GoFiles[0])
+		if !isSubDir(g.modDir, dir) {
+			return
+		}
+		dirs[dir] = true
+		for _, imp := range p.Imports() {
+			visit(imp)
+		}
+	}
+	visit(pkg)
+	return dirs
+}
+
+// moduleDir returns the directory of the module that contains the working
+// directory.
+func moduleDir() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", errors.Wrap(err, errFindModuleDir)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, goModFile)); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New(errFindModuleDir)
+		}
+		dir = parent
+	}
+}
+
+func isSubDir(parent, dir string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
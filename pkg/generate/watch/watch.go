@@ -0,0 +1,195 @@
+// Package watch reruns generators whenever the Go packages they are
+// generated from change.
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+const (
+	errCreateWatcher = "failed to create file watcher"
+	errFmtWatchDir   = "failed to watch directory %s"
+
+	// debounce is the time to wait for further changes before rerunning.
+	debounce = 200 * time.Millisecond
+
+	clearScreen = "\033[H\033[2J"
+)
+
+// Func generates the output. It is called with the directories whose Go
+// files changed since the last call, or with nil on the first call. It
+// returns the directories that should be watched from then on. If it returns
+// nil, the previously watched directories are kept.
+type Func func(changed []string) (dirs []string, err error)
+
+// Run calls fn once and then every time the Go files in one of the
+// directories returned by fn change, until ctx is done. Errors of fn are
+// printed to out and do not stop watching. If out is a terminal it is
+// cleared before each run, so only the result of the last run is shown.
+func Run(ctx context.Context, out io.Writer, fn Func) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, errCreateWatcher)
+	}
+	defer watcher.Close() //nolint:errcheck
+
+	w := &dirWatcher{
+		watcher: watcher,
+		out:     out,
+		fn:      fn,
+		hashes:  map[string]string{},
+	}
+	if err := w.run(nil); err != nil {
+		return err
+	}
+
+	pending := map[string]bool{}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !isGoFile(event.Name) || event.Op == fsnotify.Chmod {
+				continue
+			}
+			pending[filepath.Dir(event.Name)] = true
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(out, err)
+		case <-timer.C:
+			changed := w.changedDirs(pending)
+			pending = map[string]bool{}
+			if len(changed) == 0 {
+				continue
+			}
+			if err := w.run(changed); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+type dirWatcher struct {
+	watcher *fsnotify.Watcher
+	out     io.Writer
+	fn      Func
+
+	// hashes contains the hash of the Go files of each watched directory
+	// after the last run.
+	hashes map[string]string
+}
+
+// run calls fn and updates the watched directories. Only failures of the
+// watcher itself are returned.
+func (w *dirWatcher) run(changed []string) error {
+	if isTerminal(w.out) {
+		fmt.Fprint(w.out, clearScreen)
+	}
+	fmt.Fprintf(w.out, "%s generating\n", time.Now().Format(time.TimeOnly))
+	dirs, err := w.fn(changed)
+	if err != nil {
+		fmt.Fprintln(w.out, err)
+	} else {
+		fmt.Fprintln(w.out, "done")
+	}
+	fmt.Fprintln(w.out, "watching for changes, press Ctrl+C to stop")
+
+	if dirs == nil {
+		dirs = make([]string, 0, len(w.hashes))
+		for dir := range w.hashes {
+			dirs = append(dirs, dir)
+		}
+	}
+	return w.watch(dirs)
+}
+
+// watch sets the watched directories to dirs and records the current hash
+// of each directory, so changes made by the generators themselves do not
+// trigger another run.
+func (w *dirWatcher) watch(dirs []string) error {
+	keep := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		keep[dir] = true
+		if _, watched := w.hashes[dir]; !watched {
+			if err := w.watcher.Add(dir); err != nil {
+				return errors.Wrapf(err, errFmtWatchDir, dir)
+			}
+		}
+		w.hashes[dir] = hashGoFiles(dir)
+	}
+	for dir := range w.hashes {
+		if !keep[dir] {
+			_ = w.watcher.Remove(dir)
+			delete(w.hashes, dir)
+		}
+	}
+	return nil
+}
+
+// changedDirs returns the directories of candidates whose Go files changed
+// since the last run.
+func (w *dirWatcher) changedDirs(candidates map[string]bool) []string {
+	changed := []string{}
+	for dir := range candidates {
+		if prev, watched := w.hashes[dir]; watched && prev != hashGoFiles(dir) {
+			changed = append(changed, dir)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// hashGoFiles returns a hash of the names and contents of all Go files in
+// dir. Unreadable files are skipped.
+func hashGoFiles(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	for _, e := range entries {
+		if e.IsDir() || !isGoFile(e.Name()) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", e.Name(), len(content))
+		h.Write(content) //nolint:errcheck
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isGoFile(path string) bool {
+	return strings.HasSuffix(path, ".go")
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
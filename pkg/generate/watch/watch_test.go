@@ -0,0 +1,87 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	goFile := filepath.Join(dir, "types.go")
+	if err := os.WriteFile(goFile, []byte("package types\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := make(chan []string, 10)
+	out := &syncBuffer{}
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, out, func(changed []string) ([]string, error) {
+			calls <- changed
+			return []string{dir}, nil
+		})
+	}()
+
+	if got := receive(t, calls); got != nil {
+		t.Errorf("Run(...): first call: want nil, got %v", got)
+	}
+
+	// Files that are not Go files and Go files whose content did not
+	// change must not trigger a run.
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("docs"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(goFile, []byte("package types\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-calls:
+		t.Errorf("Run(...): unexpected call with %v", got)
+	case <-time.After(3 * debounce):
+	}
+
+	if err := os.WriteFile(goFile, []byte("package types\n\ntype A struct{}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{dir}, receive(t, calls)); diff != "" {
+		t.Errorf("Run(...): changed directories: -want, +got:\n%s", diff)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run(...): %v", err)
+	}
+}
+
+func receive(t *testing.T, calls chan []string) []string {
+	t.Helper()
+	select {
+	case changed := <-calls:
+		return changed
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run(...): fn has not been called")
+		return nil
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
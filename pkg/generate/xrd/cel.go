@@ -0,0 +1,260 @@
+package xrd
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/validation"
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+const (
+	errFmtCELRule       = "invalid CEL rule of %s (%s): %s"
+	errFmtInvalidCELXRD = "XRD %s has invalid CEL rules"
+	errConvertCRD       = "failed to convert CRD to internal version"
+)
+
+// celError is a CEL validation rule of a version schema that does not
+// compile or exceeds the cost budget.
+type celError struct {
+	// Version is the name of the version.
+	Version string
+
+	// Path is the path of the schema that contains the rule, e.g.
+	// [spec parameters]. Elements of arrays and maps are [*].
+	Path []string
+
+	// Detail describes the error.
+	Detail string
+}
+
+// checkCELRules compiles all x-kubernetes-validations rules of the XRDs like
+// the API server does and reports invalid rules at the Go type or field they
+// have been defined on. It returns an error if any rule is invalid.
+func checkCELRules(xrds []*xapiext.CompositeResourceDefinition, p *Parser) error {
+	invalid := []string{}
+	for _, xrd := range xrds {
+		compositeCRD, err := buildCRD(xrd, *xrd.Spec.Names.DeepCopy(), apiext.ClusterScoped)
+		if err != nil {
+			return err
+		}
+		celErrs, err := compileCELRules(compositeCRD)
+		if err != nil {
+			return err
+		}
+		for _, e := range celErrs {
+			pkg, node, source := p.fieldSource(xrd, e.Version, e.Path)
+			err := errors.Errorf(errFmtCELRule, source, formatSchemaPath(e.Version, e.Path), e.Detail)
+			if pkg == nil {
+				return err
+			}
+			pkg.AddError(loader.ErrFromNode(err, node))
+		}
+		if len(celErrs) > 0 {
+			invalid = append(invalid, xrd.GetName())
+		}
+	}
+	if len(invalid) > 0 {
+		return errors.Errorf(errFmtInvalidCELXRD, strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// compileCELRules validates the CRD like the API server does and returns
+// the errors of all x-kubernetes-validations rules. All other validation
+// errors are ignored.
+func compileCELRules(c *apiext.CustomResourceDefinition) ([]celError, error) {
+	internal := &apiextensions.CustomResourceDefinition{}
+	if err := apiext.Convert_v1_CustomResourceDefinition_To_apiextensions_CustomResourceDefinition(c, internal, nil); err != nil {
+		return nil, errors.Wrap(err, errConvertCRD)
+	}
+	celErrs := []celError{}
+	for _, e := range validation.ValidateCustomResourceDefinition(context.Background(), internal) {
+		if !strings.Contains(e.Field, "x-kubernetes-validations") && !strings.Contains(e.Detail, "x-kubernetes-validations") {
+			continue
+		}
+		celErrs = append(celErrs, celError{
+			Version: schemaVersion(c, e.Field),
+			Path:    parseSchemaPath(e.Field),
+			Detail:  e.ErrorBody(),
+		})
+	}
+	return celErrs, nil
+}
+
+// schemaPathSegment matches the segments of a field path of a CRD
+// validation error that lead to a nested schema.
+var schemaPathSegment = regexp.MustCompile(`^(properties\[(.*)\]|items|additionalProperties)$`)
+
+// parseSchemaPath returns the schema path of the given field path of a CRD
+// validation error, e.g. [spec parameters] for
+// spec.versions[0].schema.openAPIV3Schema.properties[spec].properties[parameters].x-kubernetes-validations[0].rule.
+func parseSchemaPath(fieldPath string) []string {
+	_, rest, found := strings.Cut(fieldPath, "openAPIV3Schema")
+	if !found {
+		return nil
+	}
+	path := []string{}
+	for rest != "" {
+		rest = strings.TrimPrefix(rest, ".")
+		segment := rest
+		if strings.HasPrefix(rest, "properties[") {
+			// Property names may contain dots.
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				break
+			}
+			segment = rest[:end+1]
+		} else if i := strings.Index(rest, "."); i >= 0 {
+			segment = rest[:i]
+		}
+		rest = strings.TrimPrefix(rest, segment)
+		m := schemaPathSegment.FindStringSubmatch(segment)
+		if m == nil {
+			break
+		}
+		if m[2] != "" {
+			path = append(path, m[2])
+		} else {
+			path = append(path, "[*]")
+		}
+	}
+	return path
+}
+
+// formatSchemaPath returns the schema path of the version as string, e.g.
+// v1alpha1.status.conditions[*].type.
+func formatSchemaPath(version string, path []string) string {
+	b := &strings.Builder{}
+	b.WriteString(version)
+	for _, segment := range path {
+		if segment != "[*]" {
+			b.WriteString(".")
+		}
+		b.WriteString(segment)
+	}
+	return b.String()
+}
+
+// schemaVersionIndex matches the version of a field path of a CRD
+// validation error.
+var schemaVersionIndex = regexp.MustCompile(`^spec\.versions\[(\d+)\]`)
+
+// schemaVersion returns the name of the version the given field path of a
+// CRD validation error belongs to. Errors of a schema that is shared by all
+// versions belong to the storage version.
+func schemaVersion(c *apiext.CustomResourceDefinition, fieldPath string) string {
+	if m := schemaVersionIndex.FindStringSubmatch(fieldPath); m != nil {
+		if i, err := strconv.Atoi(m[1]); err == nil && i < len(c.Spec.Versions) {
+			return c.Spec.Versions[i].Name
+		}
+	}
+	for _, v := range c.Spec.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// fieldSource returns the Go field of the given schema path of an XRD
+// version together with its package and AST node. If the path cannot be
+// followed, the innermost type or field that has been found is returned.
+// pkg is nil if the XRD has no Go type for the version.
+func (p *Parser) fieldSource(xrd *xapiext.CompositeResourceDefinition, version string, path []string) (pkg *loader.Package, node ast.Node, name string) {
+	var info *markers.TypeInfo
+	for typePkg, gv := range p.GroupVersions {
+		if gv.Group == xrd.Spec.Group && gv.Version == version {
+			if info = p.Types[crd.TypeIdent{Package: typePkg, Name: xrd.Spec.Names.Kind}]; info != nil {
+				pkg = typePkg
+				break
+			}
+		}
+	}
+	if info == nil {
+		return nil, nil, xrd.Spec.Names.Kind
+	}
+	node, name = info.RawSpec, pkg.PkgPath+"."+info.Name
+
+	for _, segment := range path {
+		if segment == "[*]" {
+			// Element types are resolved together with the field.
+			continue
+		}
+		fieldPkg, owner, field := p.findField(pkg, info, segment)
+		if field == nil {
+			return pkg, node, name
+		}
+		pkg, node, name = fieldPkg, field.RawField, fieldPkg.PkgPath+"."+owner.Name+"."+field.Name
+		if info, pkg = p.fieldType(fieldPkg, field); info == nil {
+			return fieldPkg, node, name
+		}
+	}
+	return pkg, node, name
+}
+
+// findField returns the field of the type with the given JSON name. Fields
+// of embedded types are found as well, so the returned package and owner
+// type may differ from the given ones.
+func (p *Parser) findField(pkg *loader.Package, info *markers.TypeInfo, jsonName string) (*loader.Package, *markers.TypeInfo, *markers.FieldInfo) {
+	for i, f := range info.Fields {
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == jsonName {
+			return pkg, info, &info.Fields[i]
+		}
+		embedded := len(f.RawField.Names) == 0
+		if name == "" && (embedded || strings.Contains(opts, "inline")) {
+			embeddedInfo, embeddedPkg := p.fieldType(pkg, &info.Fields[i])
+			if embeddedInfo == nil {
+				continue
+			}
+			if fieldPkg, owner, field := p.findField(embeddedPkg, embeddedInfo, jsonName); field != nil {
+				return fieldPkg, owner, field
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// fieldType returns the named type of the field. Pointers, slices, arrays
+// and maps are resolved to their element type. Types of imported packages
+// are loaded on demand.
+func (p *Parser) fieldType(pkg *loader.Package, field *markers.FieldInfo) (*markers.TypeInfo, *loader.Package) {
+	if pkg.TypesInfo == nil {
+		return nil, nil
+	}
+	typ := pkg.TypesInfo.TypeOf(field.RawField.Type)
+	for {
+		withElem, ok := typ.(interface{ Elem() types.Type })
+		if !ok {
+			break
+		}
+		typ = withElem.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil, nil
+	}
+	typePkg := pkg
+	if path := named.Obj().Pkg().Path(); path != pkg.PkgPath {
+		if typePkg = pkg.Imports()[path]; typePkg == nil {
+			return nil, nil
+		}
+		p.NeedPackage(typePkg)
+	}
+	info := p.Types[crd.TypeIdent{Package: typePkg, Name: named.Obj().Name()}]
+	if info == nil {
+		return nil, nil
+	}
+	return info, typePkg
+}
@@ -0,0 +1,83 @@
+package xrd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/utils/ptr"
+)
+
+func TestValidateCEL(t *testing.T) {
+	cases := map[string]struct {
+		reason    string
+		generator Generator
+		root      string
+		wantErr   string
+		wantFiles int
+		// wantPkgErr is the prefix of the expected package error.
+		wantPkgErr string
+	}{
+		"Valid": {
+			reason:    "XRDs with valid CEL rules are written.",
+			root:      "./testdata/celvalid",
+			wantFiles: 1,
+		},
+		"Invalid": {
+			reason:     "Invalid rules are reported at their field and no XRD is written by default.",
+			root:       "./testdata/celinvalid",
+			wantErr:    "XRD xtests.test.example.org has invalid CEL rules",
+			wantPkgErr: "invalid CEL rule of github.com/mistermx/crossbuilder/pkg/generate/xrd/testdata/celinvalid.XTestSpec.Range (v1alpha1.spec.range): ",
+		},
+		"Disabled": {
+			reason:    "The check can be disabled.",
+			generator: Generator{ValidateCEL: ptr.To(false)},
+			root:      "./testdata/celinvalid",
+			wantFiles: 1,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mem, pkgs, err := generate(t, tc.generator, tc.root)
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if diff := cmp.Diff(tc.wantErr, gotErr); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want, +got error:\n%s", tc.reason, diff)
+			}
+			if got := len(mem.Files()); got != tc.wantFiles {
+				t.Errorf("\n%s\nGenerate(...): want %d files, got %d", tc.reason, tc.wantFiles, got)
+			}
+			pkgErrs := []string{}
+			for _, pkg := range pkgs {
+				for _, e := range pkg.Errors {
+					pkgErrs = append(pkgErrs, e.Msg)
+				}
+			}
+			switch {
+			case tc.wantPkgErr == "" && len(pkgErrs) > 0:
+				t.Errorf("\n%s\nGenerate(...): want no package errors, got %v", tc.reason, pkgErrs)
+			case tc.wantPkgErr != "" && (len(pkgErrs) != 1 || !strings.HasPrefix(pkgErrs[0], tc.wantPkgErr)):
+				t.Errorf("\n%s\nGenerate(...): want package error %q..., got %v", tc.reason, tc.wantPkgErr, pkgErrs)
+			}
+		})
+	}
+}
+
+func TestParseSchemaPath(t *testing.T) {
+	cases := map[string][]string{
+		"spec.versions[0].schema.openAPIV3Schema.properties[spec].properties[parameters].x-kubernetes-validations[0].rule": {"spec", "parameters"},
+		"spec.validation.openAPIV3Schema.properties[status].properties[conditions].items.x-kubernetes-validations[0].rule": {"status", "conditions", "[*]"},
+		"spec.versions[0].schema.openAPIV3Schema.properties[spec].properties[a.b].additionalProperties":                    {"spec", "a.b", "[*]"},
+		"spec.names.kind": nil,
+	}
+	for fieldPath, want := range cases {
+		if diff := cmp.Diff(want, parseSchemaPath(fieldPath)); diff != "" {
+			t.Errorf("parseSchemaPath(%q): -want, +got:\n%s", fieldPath, diff)
+		}
+	}
+	if got := formatSchemaPath("v1alpha1", []string{"status", "conditions", "[*]", "type"}); got != "v1alpha1.status.conditions[*].type" {
+		t.Errorf("formatSchemaPath(...): got %q", got)
+	}
+}
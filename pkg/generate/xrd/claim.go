@@ -0,0 +1,224 @@
+package xrd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/layout"
+)
+
+const (
+	errFmtParseXRDSchema = "failed to parse schema of version %s of XRD %s"
+)
+
+// claimPrinterColumns are the printer columns Crossplane adds to every
+// claim CRD.
+var claimPrinterColumns = []apiext.CustomResourceColumnDefinition{
+	{Name: "SYNCED", Type: "string", JSONPath: ".status.conditions[?(@.type=='Synced')].status"},
+	{Name: "READY", Type: "string", JSONPath: ".status.conditions[?(@.type=='Ready')].status"},
+	{Name: "CONNECTION-SECRET", Type: "string", JSONPath: ".spec.writeConnectionSecretToRef.name"},
+	{Name: "AGE", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+}
+
+// ClaimPreviewGenerator generates previews of the claim CRDs Crossplane
+// creates for XRDs with claim names. Like Crossplane, the previews contain
+// the printer columns of the XRD followed by the columns Crossplane adds to
+// every claim. The preview-only columns of
+// +crossbuilder:generate:xrd:claimPrintColumn, which have no field in the
+// XRD, are inserted in between. The previews are meant for documentation
+// and review and must not be installed or packaged.
+//
+// The schema of a preview is the XRD schema without the fields Crossplane
+// injects into claims.
+type ClaimPreviewGenerator struct {
+	// IgnoreUnexportedFields indicates that we should skip unexported fields.
+	//
+	// Left unspecified, the default is false.
+	IgnoreUnexportedFields *bool `marker:",optional"`
+
+	// AllowDangerousTypes allows types which are usually omitted from CRD
+	// generation because they are not recommended.
+	//
+	// Left unspecified, the default is false
+	AllowDangerousTypes *bool `marker:",optional"`
+
+	// MaxDescLen specifies the maximum description length for fields in the
+	// OpenAPI schema.
+	MaxDescLen *int `marker:",optional"`
+
+	// CRDVersions specifies the target API versions of the CRD type itself to
+	// generate. Defaults to v1.
+	CRDVersions []string `marker:"crdVersions,optional"`
+
+	// GenerateEmbeddedObjectMeta specifies if any embedded ObjectMeta in the
+	// CRD should be generated
+	GenerateEmbeddedObjectMeta *bool `marker:",optional"`
+
+	// StripCrossplaneFields removes the fields Crossplane injects into
+	// composite resources and claims from all schemas.
+	//
+	// Left unspecified, the default is false.
+	StripCrossplaneFields *bool `marker:",optional"`
+
+	// StripFields removes the fields with the given dot separated paths from
+	// all schemas that contain them. Schemas without a field are left
+	// untouched.
+	StripFields []string `marker:",optional"`
+
+	// FileName is a Go text/template for the path of each claim CRD relative
+	// to the output directory. The template is executed with layout.Data,
+	// Kind and Plural are the claim names.
+	//
+	// Left unspecified, <group>_<claim plural>.yaml is used.
+	FileName string `marker:",optional"`
+
+	// Header prefixes every file with a comment that marks it as generated.
+	//
+	// Left unspecified, the default is false.
+	Header *bool `marker:",optional"`
+}
+
+// CheckFilter returns the node filter for this generator.
+func (ClaimPreviewGenerator) CheckFilter() loader.NodeFilter {
+	return filterTypesForCRDs
+}
+
+// RegisterMarkers registers the markers used by this generator.
+func (ClaimPreviewGenerator) RegisterMarkers(into *markers.Registry) error {
+	return Generator{}.RegisterMarkers(into)
+}
+
+// Generate generates the claim CRD previews for the given GenerationContext.
+func (g ClaimPreviewGenerator) Generate(ctx *genall.GenerationContext) error {
+	xrdGenerator := Generator{
+		IgnoreUnexportedFields:     g.IgnoreUnexportedFields,
+		AllowDangerousTypes:        g.AllowDangerousTypes,
+		MaxDescLen:                 g.MaxDescLen,
+		CRDVersions:                g.CRDVersions,
+		GenerateEmbeddedObjectMeta: g.GenerateEmbeddedObjectMeta,
+		StripCrossplaneFields:      g.StripCrossplaneFields,
+		StripFields:                g.StripFields,
+	}
+	xrds, xrdParser, err := xrdGenerator.generateXRDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	var fileNameTmpl *layout.Template
+	if g.FileName != "" {
+		if fileNameTmpl, err = layout.Parse(g.FileName); err != nil {
+			return err
+		}
+	}
+
+	files := []generatedFile{}
+	for _, xrd := range xrds {
+		if xrd.Spec.ClaimNames == nil {
+			continue
+		}
+		claim, err := buildClaimCRD(xrd)
+		if err != nil {
+			return err
+		}
+		for i := range claim.Spec.Versions {
+			claim.Spec.Versions[i].AdditionalPrinterColumns = append([]apiext.CustomResourceColumnDefinition{}, xrd.Spec.Versions[i].AdditionalPrinterColumns...)
+		}
+		if err := xrdParser.ApplyForClaim(xrd, claim); err != nil {
+			return err
+		}
+		for i, v := range claim.Spec.Versions {
+			claim.Spec.Versions[i].AdditionalPrinterColumns = append(v.AdditionalPrinterColumns, claimPrinterColumns...)
+		}
+		fileName, err := claimFileName(claim, fileNameTmpl)
+		if err != nil {
+			return err
+		}
+		files = append(files, generatedFile{name: fileName, obj: claim})
+	}
+	return writeFiles(ctx, g.Header != nil && *g.Header, files)
+}
+
+// buildClaimCRD returns the claim CRD Crossplane creates for the XRD,
+// without any printer columns.
+func buildClaimCRD(xrd *xapiext.CompositeResourceDefinition) (*apiext.CustomResourceDefinition, error) {
+	return buildCRD(xrd, *xrd.Spec.ClaimNames.DeepCopy(), apiext.NamespaceScoped)
+}
+
+// buildCRD returns a CRD with the given names and scope and the versions
+// and schemas of the XRD, without any printer columns.
+func buildCRD(xrd *xapiext.CompositeResourceDefinition, names apiext.CustomResourceDefinitionNames, scope apiext.ResourceScope) (*apiext.CustomResourceDefinition, error) {
+	if names.Singular == "" {
+		names.Singular = strings.ToLower(names.Kind)
+	}
+	if names.ListKind == "" {
+		names.ListKind = names.Kind + "List"
+	}
+	out := &apiext.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiext.SchemeGroupVersion.String(),
+			Kind:       "CustomResourceDefinition",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: names.Plural + "." + xrd.Spec.Group,
+		},
+		Spec: apiext.CustomResourceDefinitionSpec{
+			Group: xrd.Spec.Group,
+			Names: names,
+			Scope: scope,
+		},
+	}
+	if xrd.Spec.Metadata != nil {
+		out.SetLabels(xrd.Spec.Metadata.Labels)
+		out.SetAnnotations(xrd.Spec.Metadata.Annotations)
+	}
+	for _, v := range xrd.Spec.Versions {
+		schema := &apiext.JSONSchemaProps{}
+		if v.Schema != nil && len(v.Schema.OpenAPIV3Schema.Raw) > 0 {
+			if err := json.Unmarshal(v.Schema.OpenAPIV3Schema.Raw, schema); err != nil {
+				return nil, errors.Wrapf(err, errFmtParseXRDSchema, v.Name, xrd.GetName())
+			}
+		}
+		out.Spec.Versions = append(out.Spec.Versions, apiext.CustomResourceDefinitionVersion{
+			Name:               v.Name,
+			Served:             v.Served,
+			Storage:            v.Referenceable,
+			Deprecated:         v.Deprecated != nil && *v.Deprecated,
+			DeprecationWarning: v.DeprecationWarning,
+			Schema:             &apiext.CustomResourceValidation{OpenAPIV3Schema: schema},
+			Subresources: &apiext.CustomResourceSubresources{
+				Status: &apiext.CustomResourceSubresourceStatus{},
+			},
+		})
+	}
+	return out, nil
+}
+
+// claimFileName returns the path the given claim CRD is written to. If t is
+// nil, the default <group>_<plural>.yaml is used.
+func claimFileName(claim *apiext.CustomResourceDefinition, t *layout.Template) (string, error) {
+	if t == nil {
+		return fmt.Sprintf("%s_%s.yaml", claim.Spec.Group, claim.Spec.Names.Plural), nil
+	}
+	data := layout.Data{
+		Group:  claim.Spec.Group,
+		Kind:   claim.Spec.Names.Kind,
+		Plural: claim.Spec.Names.Plural,
+		Name:   claim.GetName(),
+		Labels: claim.GetLabels(),
+	}
+	for i, v := range claim.Spec.Versions {
+		if i == 0 || v.Storage {
+			data.Version = v.Name
+		}
+	}
+	return t.Execute(data)
+}
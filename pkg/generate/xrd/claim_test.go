@@ -0,0 +1,66 @@
+package xrd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/layout"
+)
+
+func TestClaimPreviewGenerator(t *testing.T) {
+	mem, _, err := generate(t, ClaimPreviewGenerator{}, "./testdata/claims")
+	if err != nil {
+		t.Fatalf("Generate(...): %v", err)
+	}
+
+	data, err := mem.ReadFile("test.example.org_tests.yaml")
+	if err != nil {
+		t.Fatalf("ReadFile(...): %v", err)
+	}
+	claim := &apiext.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(data, claim); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("tests.test.example.org", claim.GetName()); diff != "" {
+		t.Errorf("name: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(apiext.NamespaceScoped, claim.Spec.Scope); diff != "" {
+		t.Errorf("scope: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"platform", "crossplane"}, claim.Spec.Names.Categories); diff != "" {
+		t.Errorf("categories: -want, +got:\n%s", diff)
+	}
+	columns := []string{}
+	for _, c := range claim.Spec.Versions[0].AdditionalPrinterColumns {
+		columns = append(columns, c.Name)
+	}
+	want := []string{"SIZE", "NAMESPACE-SIZE", "SYNCED", "READY", "CONNECTION-SECRET", "AGE"}
+	if diff := cmp.Diff(want, columns); diff != "" {
+		t.Errorf("printer columns: -want, +got:\n%s", diff)
+	}
+}
+
+func TestClaimFileName(t *testing.T) {
+	claim := &apiext.CustomResourceDefinition{
+		Spec: apiext.CustomResourceDefinitionSpec{
+			Group: "test.example.org",
+			Names: apiext.CustomResourceDefinitionNames{Kind: "Test", Plural: "tests"},
+			Versions: []apiext.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1"},
+				{Name: "v1beta1", Storage: true},
+				{Name: "v1"},
+			},
+		},
+	}
+	got, err := claimFileName(claim, nil)
+	if err != nil || got != "test.example.org_tests.yaml" {
+		t.Errorf("claimFileName(...): want test.example.org_tests.yaml, got %q, %v", got, err)
+	}
+	got, err = claimFileName(claim, layout.Must("{{ .Kind }}/{{ .Version }}.yaml"))
+	if err != nil || got != "Test/v1beta1.yaml" {
+		t.Errorf("claimFileName(...): want Test/v1beta1.yaml, got %q, %v", got, err)
+	}
+}
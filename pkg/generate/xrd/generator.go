@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"go/ast"
+	"strings"
 
 	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
 	"github.com/pkg/errors"
@@ -15,6 +16,9 @@ import (
 	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+	"github.com/mistermx/crossbuilder/pkg/generate/layout"
+	"github.com/mistermx/crossbuilder/pkg/generate/provenance"
 	xrdmarkers "github.com/mistermx/crossbuilder/pkg/generate/xrd/markers"
 )
 
@@ -22,7 +26,11 @@ const (
 	errGenerateCRDs      = "failed to generate CRDs"
 	errConvertCRDtoXRD   = "failed to convert CRD to XRD"
 	errConvertJSONSchema = "failed to convert JSON schema"
-	errWriteXRD          = "failed to write XRD to YAML"
+	errFmtWriteFile      = "failed to write %s"
+
+	errFmtNoReferenceableVersion        = "XRD %s has no referenceable version: mark one version with +crossbuilder:generate:xrd:referenceable or +kubebuilder:storageversion"
+	errFmtMultipleReferenceableVersions = "XRD %s has multiple referenceable versions %s: only one version may be marked with +crossbuilder:generate:xrd:referenceable"
+	errFmtIncompleteClaimNames          = "XRD %s has claim categories but no claim names: add +crossbuilder:generate:xrd:claimNames"
 )
 
 // Generator is a generator for XRDs.
@@ -63,6 +71,48 @@ type Generator struct {
 
 	// GenerateEmbeddedObjectMeta specifies if any embedded ObjectMeta in the CRD should be generated
 	GenerateEmbeddedObjectMeta *bool `marker:",optional"`
+
+	// FileName is a Go text/template for the path of each XRD relative to
+	// the output directory, e.g. {{ .Group }}/{{ .Plural }}.yaml. The
+	// template is executed with layout.Data, Version is the referenceable
+	// version of the XRD.
+	//
+	// Left unspecified, <group>_<plural>.yaml is used.
+	FileName string `marker:",optional"`
+
+	// Provenance adds the generator version, the Go type and the hash of the
+	// spec as annotations to every XRD.
+	//
+	// Left unspecified, the default is false.
+	Provenance *bool `marker:",optional"`
+
+	// Header prefixes every file with a comment that marks it as generated.
+	//
+	// Left unspecified, the default is false.
+	Header *bool `marker:",optional"`
+
+	// StripCrossplaneFields removes the fields Crossplane injects into
+	// composite resources and claims, like spec.compositionRef or
+	// status.conditions, from all XRD schemas. Single types can be stripped
+	// with +crossbuilder:generate:xrd:stripCrossplaneFields instead.
+	//
+	// Left unspecified, the default is false.
+	StripCrossplaneFields *bool `marker:",optional"`
+
+	// StripFields removes the fields with the given dot separated paths,
+	// e.g. spec.resourceRefs, from all XRD schemas that contain them.
+	// Schemas without a field are left untouched, unlike with
+	// +crossbuilder:generate:xrd:stripFields, which requires all fields
+	// to exist in the schema of the marked type.
+	StripFields []string `marker:",optional"`
+
+	// ValidateCEL compiles all x-kubernetes-validations rules with the CEL
+	// environment of the Kubernetes API server and reports compile errors
+	// and rules that exceed the estimated cost budget. No XRD is written if
+	// any rule is invalid. Set it to false to skip the check.
+	//
+	// Left unspecified, the default is true.
+	ValidateCEL *bool `marker:",optional"`
 }
 
 // CheckFilter returns the node filter for this generator.
@@ -100,6 +150,43 @@ func (Generator) RegisterMarkers(into *markers.Registry) error {
 
 // Generate generates the XRDs for the given GenerationContext.
 func (g Generator) Generate(ctx *genall.GenerationContext) error {
+	xrds, xrdParser, err := g.generateXRDs(ctx)
+	if err != nil {
+		return err
+	}
+	if g.ValidateCEL == nil || *g.ValidateCEL {
+		if err := checkCELRules(xrds, xrdParser); err != nil {
+			return err
+		}
+	}
+
+	var fileNameTmpl *layout.Template
+	if g.FileName != "" {
+		if fileNameTmpl, err = layout.Parse(g.FileName); err != nil {
+			return err
+		}
+	}
+
+	files := make([]generatedFile, len(xrds))
+	for i, xrd := range xrds {
+		if g.Provenance != nil && *g.Provenance {
+			if err := provenance.Annotate(xrd, xrdParser.SourceType(xrd), xrd.Spec); err != nil {
+				return err
+			}
+		}
+		fileName, err := xrdFileName(xrd, fileNameTmpl)
+		if err != nil {
+			return err
+		}
+		files[i] = generatedFile{name: fileName, obj: xrd}
+	}
+	return writeFiles(ctx, g.Header != nil && *g.Header, files)
+}
+
+// generateXRDs generates the CRDs of all roots and converts them to XRDs
+// with all XRD markers applied. It returns the parser that applied the
+// markers.
+func (g Generator) generateXRDs(ctx *genall.GenerationContext) ([]*xapiext.CompositeResourceDefinition, *Parser, error) {
 	// Init CRD generator and store generated CRDs in memory
 	crdStorage := newCRDStorage()
 	crdGenerator := crd.Generator{
@@ -118,39 +205,116 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 	}
 
 	// Init XRD parser
-	xrdParser := Parser{
+	xrdParser := &Parser{
 		Collector: ctx.Collector,
 		Checker:   ctx.Checker,
 	}
 	for _, root := range ctx.Roots {
 		xrdParser.NeedPackage(root)
 	}
+	if xrdParser.invalidMarkers {
+		return nil, nil, errors.New(errInvalidMarkerValues)
+	}
 
 	if err := crdGenerator.Generate(crdGeneratorCtx); err != nil {
-		return errors.Wrap(err, errGenerateCRDs)
+		return nil, nil, errors.Wrap(err, errGenerateCRDs)
 	}
 
 	xrds := []*xapiext.CompositeResourceDefinition{}
+	markerErrs := []string{}
 	for _, crd := range crdStorage.CRDs {
-		xrd, err := convertCRDToXRD(crd)
+		xrd, err := convertCRDToXRD(crd, g.strippedFields())
 		if err != nil {
-			return errors.Wrap(err, errConvertCRDtoXRD)
+			return nil, nil, errors.Wrap(err, errConvertCRDtoXRD)
+		}
+		// Apply the markers of all XRDs before failing, so all invalid
+		// markers are reported at once.
+		if err := xrdParser.ApplyForXRD(xrd); err != nil {
+			markerErrs = append(markerErrs, err.Error())
+			continue
+		}
+		if xrd.Spec.ClaimNames != nil && xrd.Spec.ClaimNames.Kind == "" {
+			return nil, nil, errors.Errorf(errFmtIncompleteClaimNames, xrd.GetName())
+		}
+		if err := setReferenceableVersion(xrd, crd); err != nil {
+			return nil, nil, err
 		}
-		xrdParser.ApplyForXRD(xrd)
 		xrds = append(xrds, xrd)
 	}
+	if len(markerErrs) > 0 {
+		return nil, nil, errors.New(strings.Join(markerErrs, "; "))
+	}
+	return xrds, xrdParser, nil
+}
 
-	for _, xrd := range xrds {
-		fileName := fmt.Sprintf("%s_%s.yaml", xrd.Spec.Group, xrd.Spec.Names.Plural)
-		if err := ctx.WriteYAML(fileName, "", []interface{}{xrd}, genall.WithTransform(transformRemoveCRDStatus)); err != nil {
-			return errors.Wrap(err, errWriteXRD)
+// generatedFile is an object that is written to the named file.
+type generatedFile struct {
+	name string
+	obj  interface{}
+}
+
+// writeFiles writes all files to the output rule of ctx. Output rules that
+// only compare with existing files get all files at once, so all
+// differences can be reported together.
+func writeFiles(ctx *genall.GenerationContext, withHeader bool, files []generatedFile) error {
+	outCtx := ctx
+	finisher, needsAllFiles := ctx.OutputRule.(finishingOutputRule)
+	memFS := filesystem.NewMemory()
+	if needsAllFiles {
+		c := *ctx
+		c.OutputRule = OutputToFileSystem{FS: memFS}
+		outCtx = &c
+	}
+
+	header := ""
+	if withHeader {
+		header = provenance.Header(provenance.DefaultHeader)
+	}
+	for _, f := range files {
+		if err := outCtx.WriteYAML(f.name, header, []interface{}{f.obj}, genall.WithTransform(transformRemoveCRDStatus)); err != nil {
+			return errors.Wrapf(err, errFmtWriteFile, f.name)
 		}
 	}
+
+	if needsAllFiles {
+		return finisher.finish(memFS.Files())
+	}
 	return nil
 }
 
-func convertCRDToXRD(crd *apiext.CustomResourceDefinition) (*xapiext.CompositeResourceDefinition, error) {
-	xrdVersions, err := buildXRDVersions(crd.Spec.Versions)
+// strippedFields returns the fields that are removed from all XRD schemas
+// in addition to the internal fields.
+func (g Generator) strippedFields() []string {
+	fields := append([]string{}, g.StripFields...)
+	if g.StripCrossplaneFields != nil && *g.StripCrossplaneFields {
+		fields = append(fields, xrdmarkers.CrossplaneFields...)
+	}
+	return fields
+}
+
+// xrdFileName returns the path the given XRD is written to. If t is nil,
+// the default <group>_<plural>.yaml is used.
+func xrdFileName(xrd *xapiext.CompositeResourceDefinition, t *layout.Template) (string, error) {
+	if t == nil {
+		return fmt.Sprintf("%s_%s.yaml", xrd.Spec.Group, xrd.Spec.Names.Plural), nil
+	}
+	data := layout.Data{
+		Group:  xrd.Spec.Group,
+		Kind:   xrd.Spec.Names.Kind,
+		Plural: xrd.Spec.Names.Plural,
+		Name:   xrd.GetName(),
+		Labels: xrd.GetLabels(),
+	}
+	for i, v := range xrd.Spec.Versions {
+		if i == 0 || v.Referenceable {
+			data.Version = v.Name
+		}
+	}
+	return t.Execute(data)
+}
+
+func convertCRDToXRD(crd *apiext.CustomResourceDefinition, strip []string) (*xapiext.CompositeResourceDefinition, error) {
+	xrdVersions, err := buildXRDVersions(crd.Spec.Versions, strip)
 	if err != nil {
 		return nil, err
 	}
@@ -171,10 +335,10 @@ func convertCRDToXRD(crd *apiext.CustomResourceDefinition) (*xapiext.CompositeRe
 	return xrd, nil
 }
 
-func buildXRDVersions(crdVersions []apiext.CustomResourceDefinitionVersion) ([]xapiext.CompositeResourceDefinitionVersion, error) {
+func buildXRDVersions(crdVersions []apiext.CustomResourceDefinitionVersion, strip []string) ([]xapiext.CompositeResourceDefinitionVersion, error) {
 	xrdVersions := make([]xapiext.CompositeResourceDefinitionVersion, len(crdVersions))
 	for i, cV := range crdVersions {
-		schema, err := convertJSONSchemaToRawExtension(cV.Schema.OpenAPIV3Schema)
+		schema, err := convertJSONSchemaToRawExtension(cV.Schema.OpenAPIV3Schema, strip)
 		if err != nil {
 			return nil, errors.Wrap(err, errConvertJSONSchema)
 		}
@@ -194,59 +358,60 @@ func buildXRDVersions(crdVersions []apiext.CustomResourceDefinitionVersion) ([]x
 			xrdVersions[i].Deprecated = ptr.To(true)
 		}
 	}
+	return xrdVersions, nil
+}
 
-	// TODO: Exactly one version must be marked as referencable. This will break
-	//       if multiple versions exist per XRD.
-	//       There needs to be a way for the user to specify which version is
-	//       the desired one.
-	if len(xrdVersions) == 1 {
-		xrdVersions[0].Referenceable = true
+// setReferenceableVersion makes sure that exactly one version of the XRD is
+// referenceable. If no version has been marked as referenceable, the storage
+// version of the CRD is used.
+func setReferenceableVersion(xrd *xapiext.CompositeResourceDefinition, crd *apiext.CustomResourceDefinition) error {
+	referenceable := []string{}
+	for _, v := range xrd.Spec.Versions {
+		if v.Referenceable {
+			referenceable = append(referenceable, v.Name)
+		}
 	}
-
-	return xrdVersions, nil
+	if len(referenceable) > 1 {
+		return errors.Errorf(errFmtMultipleReferenceableVersions, xrd.GetName(), strings.Join(referenceable, ", "))
+	}
+	if len(referenceable) == 1 {
+		return nil
+	}
+	for _, cV := range crd.Spec.Versions {
+		if cV.Storage {
+			referenceable = append(referenceable, cV.Name)
+		}
+	}
+	if len(referenceable) == 0 && len(xrd.Spec.Versions) == 1 {
+		referenceable = append(referenceable, xrd.Spec.Versions[0].Name)
+	}
+	if len(referenceable) != 1 {
+		return errors.Errorf(errFmtNoReferenceableVersion, xrd.GetName())
+	}
+	for i, v := range xrd.Spec.Versions {
+		if v.Name == referenceable[0] {
+			xrd.Spec.Versions[i].Referenceable = true
+		}
+	}
+	return nil
 }
 
-func convertJSONSchemaToRawExtension(schema *apiext.JSONSchemaProps) (runtime.RawExtension, error) {
-	removeCrossplaneInternalFieldFromSchema(*schema)
+// internalFields are the fields of the generated CRD schema that are
+// managed by Kubernetes and Crossplane and are never part of an XRD schema.
+var internalFields = []string{"apiVersion", "kind", "metadata"}
+
+// convertJSONSchemaToRawExtension converts the schema without the internal
+// and the given fields to a raw extension. The schema is left untouched.
+func convertJSONSchemaToRawExtension(schema *apiext.JSONSchemaProps, strip []string) (runtime.RawExtension, error) {
+	schema = schema.DeepCopy()
+	xrdmarkers.RemoveFields(schema, internalFields...)
+	xrdmarkers.RemoveFields(schema, strip...)
 	rawExt := runtime.RawExtension{}
 	raw, err := json.Marshal(schema)
 	rawExt.Raw = raw
 	return rawExt, err
 }
 
-func removeCrossplaneInternalFieldFromSchema(schema apiext.JSONSchemaProps) apiext.JSONSchemaProps {
-	paths := [][]string{
-		{"apiVersion"},
-		{"kind"},
-		{"metadata"},
-		// {"spec", "claimRef"},
-		// {"spec", "compositionRef"},
-		// {"spec", "compositionRevisionRef"},
-		// {"spec", "compositionSelector"},
-		// {"spec", "publishConnectionDetailsTo"},
-		// {"spec", "resourceRefs"},
-		// {"spec", "writeConnectionSecretToRef"},
-		// {"status", "conditions"},
-		// {"status", "connectionDetails"},
-	}
-	for _, path := range paths {
-		schema = removePathFromSchema(schema, path)
-	}
-	return schema
-}
-
-func removePathFromSchema(schema apiext.JSONSchemaProps, pathSegments []string) apiext.JSONSchemaProps {
-	propName := pathSegments[0]
-	if len(pathSegments) == 1 {
-		delete(schema.Properties, propName)
-	} else if len(pathSegments) > 1 {
-		if prop, ok := schema.Properties[propName]; ok {
-			schema.Properties[propName] = removePathFromSchema(prop, pathSegments[1:])
-		}
-	}
-	return schema
-}
-
 // transformRemoveCRDStatus ensures we do not write the CRD status field.
 func transformRemoveCRDStatus(obj map[string]interface{}) error {
 	delete(obj, "status")
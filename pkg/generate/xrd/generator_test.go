@@ -0,0 +1,186 @@
+package xrd
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/google/go-cmp/cmp"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+)
+
+func TestSetReferenceableVersion(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		marked   []string
+		storage  []string
+		versions []string
+		want     []string
+		wantErr  string
+	}{
+		"Marked": {
+			reason:   "A version marked as referenceable is used even if another one is the storage version.",
+			marked:   []string{"v1alpha1"},
+			storage:  []string{"v1beta1"},
+			versions: []string{"v1alpha1", "v1beta1"},
+			want:     []string{"v1alpha1"},
+		},
+		"StorageVersion": {
+			reason:   "Without marker, the storage version is referenceable.",
+			storage:  []string{"v1beta1"},
+			versions: []string{"v1alpha1", "v1beta1"},
+			want:     []string{"v1beta1"},
+		},
+		"SingleVersion": {
+			reason:   "A single version is referenceable.",
+			versions: []string{"v1alpha1"},
+			want:     []string{"v1alpha1"},
+		},
+		"MultipleMarked": {
+			reason:   "Only one version may be marked as referenceable.",
+			marked:   []string{"v1alpha1", "v1beta1"},
+			versions: []string{"v1alpha1", "v1beta1"},
+			wantErr:  "XRD xtests.example.org has multiple referenceable versions v1alpha1, v1beta1: only one version may be marked with +crossbuilder:generate:xrd:referenceable",
+		},
+		"None": {
+			reason:   "One of several versions must be referenceable.",
+			versions: []string{"v1alpha1", "v1beta1"},
+			wantErr:  "XRD xtests.example.org has no referenceable version: mark one version with +crossbuilder:generate:xrd:referenceable or +kubebuilder:storageversion",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			xrd := &xapiext.CompositeResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "xtests.example.org"}}
+			crd := &apiext.CustomResourceDefinition{}
+			for _, v := range tc.versions {
+				xrd.Spec.Versions = append(xrd.Spec.Versions, xapiext.CompositeResourceDefinitionVersion{Name: v, Referenceable: contains(tc.marked, v)})
+				crd.Spec.Versions = append(crd.Spec.Versions, apiext.CustomResourceDefinitionVersion{Name: v, Storage: contains(tc.storage, v)})
+			}
+			err := setReferenceableVersion(xrd, crd)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("\n%s\nsetReferenceableVersion(...): want error %q, got %v", tc.reason, tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\n%s\nsetReferenceableVersion(...): %v", tc.reason, err)
+			}
+			got := []string{}
+			for _, v := range xrd.Spec.Versions {
+				if v.Referenceable {
+					got = append(got, v.Name)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nsetReferenceableVersion(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// testGenerator is a generator of this package.
+type testGenerator interface {
+	genall.Generator
+	CheckFilter() loader.NodeFilter
+}
+
+// generate runs the generator for the given root packages and writes to an
+// in-memory file system.
+func generate(t *testing.T, g testGenerator, roots ...string) (*filesystem.Memory, []*loader.Package, error) {
+	t.Helper()
+	pkgs, err := loader.LoadRoots(roots...)
+	if err != nil {
+		t.Fatalf("LoadRoots(...): %v", err)
+	}
+	reg := &markers.Registry{}
+	if err := g.RegisterMarkers(reg); err != nil {
+		t.Fatalf("RegisterMarkers(...): %v", err)
+	}
+	mem := filesystem.NewMemory()
+	ctx := &genall.GenerationContext{
+		Collector:  &markers.Collector{Registry: reg},
+		Roots:      pkgs,
+		Checker:    &loader.TypeChecker{NodeFilters: []loader.NodeFilter{g.CheckFilter()}},
+		OutputRule: OutputToFileSystem{FS: mem},
+	}
+	return mem, pkgs, g.Generate(ctx)
+}
+
+func TestGenerateInvalidMarker(t *testing.T) {
+	mem, pkgs, err := generate(t, Generator{}, "./testdata/invalidpolicy")
+	want := "markers have invalid values"
+	if err == nil || err.Error() != want {
+		t.Errorf("Generate(...): want error %q, got %v", want, err)
+	}
+	if files := mem.Files(); len(files) > 0 {
+		t.Errorf("Generate(...): want no files for invalid markers, got %d", len(files))
+	}
+	pkgErrs := []string{}
+	for _, pkg := range pkgs {
+		for _, e := range pkg.Errors {
+			pkgErrs = append(pkgErrs, filepath.Base(e.Pos)+": "+e.Msg)
+		}
+	}
+	wantPkgErrs := []string{`types.go:17:1: invalid composite delete policy "Later": must be one of Background, Foreground`}
+	if diff := cmp.Diff(wantPkgErrs, pkgErrs); diff != "" {
+		t.Errorf("Generate(...): package errors: -want, +got:\n%s", diff)
+	}
+}
+
+func TestConvertJSONSchemaToRawExtensionStrip(t *testing.T) {
+	schema := &apiext.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiext.JSONSchemaProps{
+			"kind": {Type: "string"},
+			"spec": {
+				Type: "object",
+				Properties: map[string]apiext.JSONSchemaProps{
+					"compositionRef": {Type: "object"},
+					"parameters":     {Type: "object"},
+				},
+			},
+		},
+	}
+	raw, err := convertJSONSchemaToRawExtension(schema, []string{"spec.compositionRef", "spec.unknown"})
+	if err != nil {
+		t.Fatalf("convertJSONSchemaToRawExtension(...): %v", err)
+	}
+	got := &apiext.JSONSchemaProps{}
+	if err := json.Unmarshal(raw.Raw, got); err != nil {
+		t.Fatal(err)
+	}
+	want := &apiext.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiext.JSONSchemaProps{
+			"spec": {
+				Type: "object",
+				Properties: map[string]apiext.JSONSchemaProps{
+					"parameters": {Type: "object"},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("convertJSONSchemaToRawExtension(...): fields that do not exist must be ignored: -want, +got:\n%s", diff)
+	}
+	if _, ok := schema.Properties["kind"]; !ok {
+		t.Errorf("convertJSONSchemaToRawExtension(...): schema has been modified")
+	}
+}
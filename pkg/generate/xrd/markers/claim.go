@@ -0,0 +1,78 @@
+package markers
+
+import (
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// +controllertools:marker:generateHelp:category=XRD
+
+// ClaimPrintColumn adds a preview-only printer column to the claim CRD.
+// Crossplane uses the printer columns of the XRD for composite resources and
+// claims alike, so these columns only appear in claim CRD previews generated
+// by claimpreview, after the columns of +kubebuilder:printcolumn.
+type ClaimPrintColumn struct {
+	Name        string `marker:"name"`
+	Type        string `marker:"type"`
+	JSONPath    string `marker:"JSONPath"`
+	Priority    int32  `marker:",optional"`
+	Format      string `marker:",optional"`
+	Description string `marker:",optional"`
+}
+
+// ApplyToClaim adds the printer column to the version of the claim CRD.
+func (c ClaimPrintColumn) ApplyToClaim(claim *apiext.CustomResourceDefinition, version string) error {
+	for i, v := range claim.Spec.Versions {
+		if v.Name != version {
+			continue
+		}
+		claim.Spec.Versions[i].AdditionalPrinterColumns = append(v.AdditionalPrinterColumns, apiext.CustomResourceColumnDefinition{
+			Name:        c.Name,
+			Type:        c.Type,
+			JSONPath:    c.JSONPath,
+			Priority:    c.Priority,
+			Format:      c.Format,
+			Description: c.Description,
+		})
+		return nil
+	}
+	return errors.Errorf(errFmtUnknownVersion, version)
+}
+
+// MarkerKeys returns the column name.
+func (c ClaimPrintColumn) MarkerKeys() []string {
+	return []string{c.Name}
+}
+
+// +controllertools:marker:generateHelp:category=XRD
+
+// ClaimCategories is a marker to add categories to the claim names of an
+// XRD. Requires +crossbuilder:generate:xrd:claimNames.
+type ClaimCategories []string
+
+// ApplyToXRD adds the categories to the claim names of the XRD.
+func (c ClaimCategories) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
+	if xrd.Spec.ClaimNames == nil {
+		xrd.Spec.ClaimNames = &apiext.CustomResourceDefinitionNames{}
+	}
+	xrd.Spec.ClaimNames.Categories = appendUnique(xrd.Spec.ClaimNames.Categories, c...)
+	return nil
+}
+
+// appendUnique appends all values to s that are not in s yet.
+func appendUnique(s []string, values ...string) []string {
+	for _, v := range values {
+		exists := false
+		for _, e := range s {
+			if e == v {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			s = append(s, v)
+		}
+	}
+	return s
+}
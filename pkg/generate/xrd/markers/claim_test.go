@@ -0,0 +1,46 @@
+package markers
+
+import (
+	"testing"
+
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/google/go-cmp/cmp"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestClaimPrintColumn(t *testing.T) {
+	claim := &apiext.CustomResourceDefinition{
+		Spec: apiext.CustomResourceDefinitionSpec{
+			Versions: []apiext.CustomResourceDefinitionVersion{{Name: "v1alpha1"}, {Name: "v1beta1"}},
+		},
+	}
+	c := ClaimPrintColumn{Name: "SIZE", Type: "integer", JSONPath: ".spec.size", Priority: 1}
+	if err := c.ApplyToClaim(claim, "v1beta1"); err != nil {
+		t.Fatalf("ApplyToClaim(...): %v", err)
+	}
+	want := []apiext.CustomResourceColumnDefinition{{Name: "SIZE", Type: "integer", JSONPath: ".spec.size", Priority: 1}}
+	if diff := cmp.Diff(want, claim.Spec.Versions[1].AdditionalPrinterColumns); diff != "" {
+		t.Errorf("ApplyToClaim(...): -want, +got:\n%s", diff)
+	}
+	if len(claim.Spec.Versions[0].AdditionalPrinterColumns) > 0 {
+		t.Errorf("ApplyToClaim(...): column added to other version")
+	}
+	if err := c.ApplyToClaim(claim, "v1"); err == nil {
+		t.Errorf("ApplyToClaim(...): want error for unknown version")
+	}
+}
+
+func TestClaimCategories(t *testing.T) {
+	xrd := &xapiext.CompositeResourceDefinition{}
+	if err := (ClaimCategories{"platform", "crossplane"}).ApplyToXRD(xrd, "v1alpha1"); err != nil {
+		t.Fatalf("ClaimCategories.ApplyToXRD(...): %v", err)
+	}
+	// Claim names that are applied afterwards keep the categories.
+	if err := (ClaimNames{Kind: "Test", Plural: "tests", Categories: []string{"crossplane", "test"}}).ApplyToXRD(xrd, "v1alpha1"); err != nil {
+		t.Fatalf("ClaimNames.ApplyToXRD(...): %v", err)
+	}
+	want := &apiext.CustomResourceDefinitionNames{Kind: "Test", Plural: "tests", Categories: []string{"platform", "crossplane", "test"}}
+	if diff := cmp.Diff(want, xrd.Spec.ClaimNames); diff != "" {
+		t.Errorf("ApplyToXRD(...): -want, +got:\n%s", diff)
+	}
+}
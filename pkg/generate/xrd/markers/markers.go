@@ -1,12 +1,20 @@
 package markers
 
 import (
+	"strings"
+
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
 	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
+const (
+	errFmtUnknownVersion = "XRD has no version %q"
+	errFmtInvalidValue   = "invalid %s %q: must be one of %s"
+)
+
 // XRDMarkers lists all markers that directly modify the XRD (not validation
 // schemas).
 var XRDMarkers = []*definitionWithHelp{
@@ -14,7 +22,16 @@ var XRDMarkers = []*definitionWithHelp{
 	must(markers.MakeDefinition("crossbuilder:generate:xrd:defaultCompositionRef", markers.DescribesType, DefaultCompositionRef{})),
 	must(markers.MakeDefinition("crossbuilder:generate:xrd:enforcedCompositionRef", markers.DescribesType, EnforcedCompositionRef{})),
 	must(markers.MakeDefinition("crossbuilder:generate:xrd:defaultCompositeDeletePolicy", markers.DescribesType, DefaultCompositeDeletePolicy{})),
+	must(markers.MakeDefinition("crossbuilder:generate:xrd:defaultCompositionUpdatePolicy", markers.DescribesType, DefaultCompositionUpdatePolicy{})),
 	must(markers.MakeDefinition("crossbuilder:generate:xrd:connectionSecretKeys", markers.DescribesType, ConnectionSecretKeys(nil))),
+	must(markers.MakeDefinition("crossbuilder:generate:xrd:referenceable", markers.DescribesType, Referenceable{})),
+	must(markers.MakeDefinition("crossbuilder:generate:xrd:stripCrossplaneFields", markers.DescribesType, StripCrossplaneFields{})),
+	must(markers.MakeDefinition("crossbuilder:generate:xrd:stripFields", markers.DescribesType, StripFields(nil))),
+	must(markers.MakeDefinition("crossbuilder:generate:xrd:label", markers.DescribesType, Label{})),
+	must(markers.MakeDefinition("crossbuilder:generate:xrd:annotation", markers.DescribesType, Annotation{})),
+	must(markers.MakeDefinition("crossbuilder:generate:xrd:crdMetadata", markers.DescribesType, CRDMetadata{})),
+	must(markers.MakeDefinition("crossbuilder:generate:xrd:claimPrintColumn", markers.DescribesType, ClaimPrintColumn{})),
+	must(markers.MakeDefinition("crossbuilder:generate:xrd:claimCategories", markers.DescribesType, ClaimCategories(nil))),
 }
 
 func init() {
@@ -33,17 +50,21 @@ type ClaimNames struct {
 	Categories []string `marker:"categories,optional"`
 }
 
-// ApplyToXRD applies the claim names to the XRD.
+// ApplyToXRD applies the claim names to the XRD. Categories that have been
+// added by +crossbuilder:generate:xrd:claimCategories are kept.
 func (c ClaimNames) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
+	categories := c.Categories
+	if xrd.Spec.ClaimNames != nil {
+		categories = appendUnique(append([]string{}, xrd.Spec.ClaimNames.Categories...), c.Categories...)
+	}
 	xrd.Spec.ClaimNames = &apiext.CustomResourceDefinitionNames{
 		Kind:       c.Kind,
 		Plural:     c.Plural,
 		Singular:   c.Singular,
 		ShortNames: c.ShortNames,
 		ListKind:   c.ListKind,
-		Categories: c.Categories,
+		Categories: categories,
 	}
-	// test(c)
 	return nil
 }
 
@@ -89,13 +110,49 @@ type DefaultCompositeDeletePolicy struct {
 	Policy xpv1.CompositeDeletePolicy `marker:"policy"`
 }
 
-// ApplyToXRD applies the enforced composition ref to the XRD.
+// Validate returns an error if the policy is not supported by Crossplane.
+func (c DefaultCompositeDeletePolicy) Validate() error {
+	return validateEnum("composite delete policy", c.Policy, xpv1.CompositeDeleteBackground, xpv1.CompositeDeleteForeground)
+}
+
+// ApplyToXRD applies the default composite delete policy to the XRD.
 func (c DefaultCompositeDeletePolicy) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
 	xrd.Spec.DefaultCompositeDeletePolicy = &c.Policy
-	// test(c)
 	return nil
 }
 
+// +controllertools:marker:generateHelp:category=XRD
+
+// DefaultCompositionUpdatePolicy is a marker to specify whether composite
+// resources of an XRD are updated to new composition revisions
+// automatically or manually by default.
+type DefaultCompositionUpdatePolicy struct {
+	Policy xpv1.UpdatePolicy `marker:"policy"`
+}
+
+// Validate returns an error if the policy is not supported by Crossplane.
+func (c DefaultCompositionUpdatePolicy) Validate() error {
+	return validateEnum("composition update policy", c.Policy, xpv1.UpdateAutomatic, xpv1.UpdateManual)
+}
+
+// ApplyToXRD applies the default composition update policy to the XRD.
+func (c DefaultCompositionUpdatePolicy) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
+	xrd.Spec.DefaultCompositionUpdatePolicy = &c.Policy
+	return nil
+}
+
+// validateEnum returns an error if value is none of the allowed values.
+func validateEnum[T ~string](name string, value T, allowed ...T) error {
+	names := make([]string, len(allowed))
+	for i, a := range allowed {
+		if value == a {
+			return nil
+		}
+		names[i] = string(a)
+	}
+	return errors.Errorf(errFmtInvalidValue, name, value, strings.Join(names, ", "))
+}
+
 // ConnectionSecretKeys is a marker to specify connection secret keys of an XRD
 type ConnectionSecretKeys []string
 
@@ -103,3 +160,21 @@ func (c ConnectionSecretKeys) ApplyToXRD(xrd *xapiext.CompositeResourceDefinitio
 	xrd.Spec.ConnectionSecretKeys = c
 	return nil
 }
+
+// +controllertools:marker:generateHelp:category=XRD
+
+// Referenceable marks the version of the type as the referenceable version
+// of the XRD. Exactly one version must be referenceable. Defaults to the
+// storage version.
+type Referenceable struct{}
+
+// ApplyToXRD marks the given version as referenceable.
+func (Referenceable) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
+	for i, v := range xrd.Spec.Versions {
+		if v.Name == version {
+			xrd.Spec.Versions[i].Referenceable = true
+			return nil
+		}
+	}
+	return errors.Errorf(errFmtUnknownVersion, version)
+}
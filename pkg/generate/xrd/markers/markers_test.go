@@ -0,0 +1,78 @@
+package markers
+
+import (
+	"testing"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+)
+
+func TestReferenceable(t *testing.T) {
+	xrd := &xapiext.CompositeResourceDefinition{
+		Spec: xapiext.CompositeResourceDefinitionSpec{
+			Versions: []xapiext.CompositeResourceDefinitionVersion{{Name: "v1alpha1"}, {Name: "v1beta1"}},
+		},
+	}
+	if err := (Referenceable{}).ApplyToXRD(xrd, "v1beta1"); err != nil {
+		t.Fatalf("ApplyToXRD(...): %v", err)
+	}
+	if xrd.Spec.Versions[0].Referenceable || !xrd.Spec.Versions[1].Referenceable {
+		t.Errorf("ApplyToXRD(...): want only v1beta1 referenceable, got %+v", xrd.Spec.Versions)
+	}
+	if err := (Referenceable{}).ApplyToXRD(xrd, "v1"); err == nil {
+		t.Errorf("ApplyToXRD(...): want error for unknown version")
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	cases := map[string]struct {
+		marker interface {
+			Validate() error
+			ApplyToXRD(*xapiext.CompositeResourceDefinition, string) error
+		}
+		check   func(xrd *xapiext.CompositeResourceDefinition) bool
+		wantErr string
+	}{
+		"DeletePolicy": {
+			marker: DefaultCompositeDeletePolicy{Policy: xpv1.CompositeDeleteForeground},
+			check: func(xrd *xapiext.CompositeResourceDefinition) bool {
+				return *xrd.Spec.DefaultCompositeDeletePolicy == xpv1.CompositeDeleteForeground
+			},
+		},
+		"InvalidDeletePolicy": {
+			marker:  DefaultCompositeDeletePolicy{Policy: "Later"},
+			wantErr: `invalid composite delete policy "Later": must be one of Background, Foreground`,
+		},
+		"UpdatePolicy": {
+			marker: DefaultCompositionUpdatePolicy{Policy: xpv1.UpdateManual},
+			check: func(xrd *xapiext.CompositeResourceDefinition) bool {
+				return *xrd.Spec.DefaultCompositionUpdatePolicy == xpv1.UpdateManual
+			},
+		},
+		"InvalidUpdatePolicy": {
+			marker:  DefaultCompositionUpdatePolicy{Policy: "manual"},
+			wantErr: `invalid composition update policy "manual": must be one of Automatic, Manual`,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.marker.Validate()
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("Validate(): want error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate(): %v", err)
+			}
+			xrd := &xapiext.CompositeResourceDefinition{}
+			if err := tc.marker.ApplyToXRD(xrd, "v1alpha1"); err != nil {
+				t.Fatalf("ApplyToXRD(...): %v", err)
+			}
+			if !tc.check(xrd) {
+				t.Errorf("ApplyToXRD(...): policy has not been set: %+v", xrd.Spec)
+			}
+		})
+	}
+}
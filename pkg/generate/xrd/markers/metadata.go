@@ -0,0 +1,117 @@
+package markers
+
+import (
+	"sort"
+
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	errFmtConflictingValue = "%s %q is already set to %q"
+)
+
+// +controllertools:marker:generateHelp:category=XRD
+
+// Label is a marker to add a label to the XRD. It may be repeated, but
+// every key must only be set once.
+type Label struct {
+	Key   string `marker:"key"`
+	Value string `marker:"value"`
+}
+
+// ApplyToXRD adds the label to the XRD.
+func (l Label) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
+	labels, err := setKey(xrd.GetLabels(), "label", l.Key, l.Value)
+	xrd.SetLabels(labels)
+	return err
+}
+
+// MarkerKeys returns the label key.
+func (l Label) MarkerKeys() []string {
+	return []string{l.Key}
+}
+
+// +controllertools:marker:generateHelp:category=XRD
+
+// Annotation is a marker to add an annotation to the XRD. It may be
+// repeated, but every key must only be set once.
+type Annotation struct {
+	Key   string `marker:"key"`
+	Value string `marker:"value"`
+}
+
+// ApplyToXRD adds the annotation to the XRD.
+func (a Annotation) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
+	annotations, err := setKey(xrd.GetAnnotations(), "annotation", a.Key, a.Value)
+	xrd.SetAnnotations(annotations)
+	return err
+}
+
+// MarkerKeys returns the annotation key.
+func (a Annotation) MarkerKeys() []string {
+	return []string{a.Key}
+}
+
+// +controllertools:marker:generateHelp:category=XRD
+
+// CRDMetadata is a marker to set the labels and annotations Crossplane adds
+// to the composite resource and claim CRDs it generates from the XRD
+// (spec.metadata). It may be repeated, but every key must only be set once.
+type CRDMetadata struct {
+	Labels      map[string]string `marker:"labels,optional"`
+	Annotations map[string]string `marker:"annotations,optional"`
+}
+
+// ApplyToXRD adds the labels and annotations to spec.metadata of the XRD.
+func (c CRDMetadata) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
+	if xrd.Spec.Metadata == nil {
+		xrd.Spec.Metadata = &xapiext.CompositeResourceDefinitionSpecMetadata{}
+	}
+	var err error
+	for _, k := range sortedKeys(c.Labels) {
+		if xrd.Spec.Metadata.Labels, err = setKey(xrd.Spec.Metadata.Labels, "CRD label", k, c.Labels[k]); err != nil {
+			return err
+		}
+	}
+	for _, k := range sortedKeys(c.Annotations) {
+		if xrd.Spec.Metadata.Annotations, err = setKey(xrd.Spec.Metadata.Annotations, "CRD annotation", k, c.Annotations[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkerKeys returns the label and annotation keys.
+func (c CRDMetadata) MarkerKeys() []string {
+	keys := []string{}
+	for _, k := range sortedKeys(c.Labels) {
+		keys = append(keys, "labels/"+k)
+	}
+	for _, k := range sortedKeys(c.Annotations) {
+		keys = append(keys, "annotations/"+k)
+	}
+	return keys
+}
+
+// setKey sets key to value in m. Setting a key to a different value than it
+// already has is an error, because multiple versions of an XRD may set it.
+func setKey(m map[string]string, kind, key, value string) (map[string]string, error) {
+	if m == nil {
+		m = map[string]string{}
+	}
+	if existing, ok := m[key]; ok && existing != value {
+		return m, errors.Errorf(errFmtConflictingValue, kind, key, existing)
+	}
+	m[key] = value
+	return m, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
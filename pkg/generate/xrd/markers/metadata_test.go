@@ -0,0 +1,62 @@
+package markers
+
+import (
+	"testing"
+
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLabelAndAnnotation(t *testing.T) {
+	xrd := &xapiext.CompositeResourceDefinition{}
+	for _, version := range []string{"v1alpha1", "v1beta1"} {
+		// Every version of the type carries the same markers.
+		if err := (Label{Key: "team", Value: "platform"}).ApplyToXRD(xrd, version); err != nil {
+			t.Fatalf("Label.ApplyToXRD(...): %v", err)
+		}
+		if err := (Annotation{Key: "docs", Value: "https://example.org"}).ApplyToXRD(xrd, version); err != nil {
+			t.Fatalf("Annotation.ApplyToXRD(...): %v", err)
+		}
+	}
+	if diff := cmp.Diff(map[string]string{"team": "platform"}, xrd.GetLabels()); diff != "" {
+		t.Errorf("Label.ApplyToXRD(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"docs": "https://example.org"}, xrd.GetAnnotations()); diff != "" {
+		t.Errorf("Annotation.ApplyToXRD(...): -want, +got:\n%s", diff)
+	}
+
+	want := `label "team" is already set to "platform"`
+	if err := (Label{Key: "team", Value: "other"}).ApplyToXRD(xrd, "v1"); err == nil || err.Error() != want {
+		t.Errorf("Label.ApplyToXRD(...): want error %q, got %v", want, err)
+	}
+	want = `annotation "docs" is already set to "https://example.org"`
+	if err := (Annotation{Key: "docs", Value: "other"}).ApplyToXRD(xrd, "v1"); err == nil || err.Error() != want {
+		t.Errorf("Annotation.ApplyToXRD(...): want error %q, got %v", want, err)
+	}
+}
+
+func TestCRDMetadata(t *testing.T) {
+	m := CRDMetadata{
+		Labels:      map[string]string{"b": "2", "a": "1"},
+		Annotations: map[string]string{"c": "3"},
+	}
+	xrd := &xapiext.CompositeResourceDefinition{}
+	if err := m.ApplyToXRD(xrd, "v1alpha1"); err != nil {
+		t.Fatalf("ApplyToXRD(...): %v", err)
+	}
+	want := &xapiext.CompositeResourceDefinitionSpecMetadata{
+		Labels:      map[string]string{"a": "1", "b": "2"},
+		Annotations: map[string]string{"c": "3"},
+	}
+	if diff := cmp.Diff(want, xrd.Spec.Metadata); diff != "" {
+		t.Errorf("ApplyToXRD(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"labels/a", "labels/b", "annotations/c"}, m.MarkerKeys()); diff != "" {
+		t.Errorf("MarkerKeys(): -want, +got:\n%s", diff)
+	}
+
+	conflict := CRDMetadata{Labels: map[string]string{"a": "other"}}
+	if err := conflict.ApplyToXRD(xrd, "v1alpha1"); err == nil {
+		t.Errorf("ApplyToXRD(...): want error for conflicting label")
+	}
+}
@@ -0,0 +1,130 @@
+package markers
+
+import (
+	"encoding/json"
+	"strings"
+
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const (
+	errFmtParseSchema   = "failed to parse schema of version %s"
+	errFmtUnknownFields = "schema of version %s has no fields %s"
+)
+
+// CrossplaneFields lists the fields Crossplane injects into the schemas of
+// composite resources and claims, see CompositeResourceSpecProps,
+// CompositeResourceClaimSpecProps and CompositeResourceStatusProps in
+// internal/xcrd/schemas.go of Crossplane. They are stripped from the XRD
+// schema if Go types embed Crossplane's types that contain them.
+var CrossplaneFields = []string{
+	"spec.claimRef",
+	"spec.compositeDeletePolicy",
+	"spec.compositionRef",
+	"spec.compositionRevisionRef",
+	"spec.compositionRevisionSelector",
+	"spec.compositionSelector",
+	"spec.compositionUpdatePolicy",
+	"spec.environmentConfigRefs",
+	"spec.publishConnectionDetailsTo",
+	"spec.resourceRef",
+	"spec.resourceRefs",
+	"spec.writeConnectionSecretToRef",
+	"status.conditions",
+	"status.connectionDetails",
+}
+
+// RemoveFields removes the fields with the given dot separated paths, e.g.
+// spec.compositionRef, from schema. Removed fields are no longer required.
+// It returns the paths that do not exist in schema.
+func RemoveFields(schema *apiext.JSONSchemaProps, paths ...string) []string {
+	missing := []string{}
+	for _, p := range paths {
+		if !removeField(schema, strings.Split(p, ".")) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+func removeField(schema *apiext.JSONSchemaProps, segments []string) bool {
+	name := segments[0]
+	prop, ok := schema.Properties[name]
+	if !ok {
+		return false
+	}
+	if len(segments) > 1 {
+		removed := removeField(&prop, segments[1:])
+		schema.Properties[name] = prop
+		return removed
+	}
+	delete(schema.Properties, name)
+	required := make([]string, 0, len(schema.Required))
+	for _, r := range schema.Required {
+		if r != name {
+			required = append(required, r)
+		}
+	}
+	schema.Required = required
+	if len(required) == 0 {
+		schema.Required = nil
+	}
+	return true
+}
+
+// removeVersionFields removes the given fields from the schema of an XRD
+// version. If ignoreMissing is false, missing fields are an error.
+func removeVersionFields(xrd *xapiext.CompositeResourceDefinition, version string, ignoreMissing bool, paths ...string) error {
+	for i, v := range xrd.Spec.Versions {
+		if v.Name != version {
+			continue
+		}
+		if v.Schema == nil || len(v.Schema.OpenAPIV3Schema.Raw) == 0 {
+			return nil
+		}
+		schema := &apiext.JSONSchemaProps{}
+		if err := json.Unmarshal(v.Schema.OpenAPIV3Schema.Raw, schema); err != nil {
+			return errors.Wrapf(err, errFmtParseSchema, version)
+		}
+		missing := RemoveFields(schema, paths...)
+		if len(missing) > 0 && !ignoreMissing {
+			return errors.Errorf(errFmtUnknownFields, version, strings.Join(missing, ", "))
+		}
+		raw, err := json.Marshal(schema)
+		if err != nil {
+			return err
+		}
+		xrd.Spec.Versions[i].Schema.OpenAPIV3Schema.Raw = raw
+		return nil
+	}
+	return errors.Errorf(errFmtUnknownVersion, version)
+}
+
+// +controllertools:marker:generateHelp:category=XRD
+
+// StripCrossplaneFields removes the fields Crossplane injects into composite
+// resources and claims, like spec.compositionRef or status.conditions, from
+// the schema of the version. Use it if the type embeds Crossplane's types.
+// Fields the type does not contain are ignored, since most types only embed
+// some of Crossplane's types.
+type StripCrossplaneFields struct{}
+
+// ApplyToXRD removes the Crossplane fields from the schema of the version.
+func (StripCrossplaneFields) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
+	return removeVersionFields(xrd, version, true, CrossplaneFields...)
+}
+
+// +controllertools:marker:generateHelp:category=XRD
+
+// StripFields removes the fields with the given dot separated paths, e.g.
+// spec.resourceRefs, from the schema of the version. All fields must exist,
+// so typos are reported. The xrd:stripFields option instead applies to all
+// XRDs and ignores fields a schema does not contain.
+type StripFields []string
+
+// ApplyToXRD removes the fields from the schema of the version.
+func (s StripFields) ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error {
+	return removeVersionFields(xrd, version, false, s...)
+}
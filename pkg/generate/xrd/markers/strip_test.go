@@ -0,0 +1,116 @@
+package markers
+
+import (
+	"encoding/json"
+	"testing"
+
+	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/google/go-cmp/cmp"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// testSchema returns a schema with spec.compositionRef, spec.parameters
+// and status.conditions, all of them required.
+func testSchema() *apiext.JSONSchemaProps {
+	return &apiext.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiext.JSONSchemaProps{
+			"spec": {
+				Type:     "object",
+				Required: []string{"compositionRef", "parameters"},
+				Properties: map[string]apiext.JSONSchemaProps{
+					"compositionRef": {Type: "object"},
+					"parameters":     {Type: "object"},
+				},
+			},
+			"status": {
+				Type:     "object",
+				Required: []string{"conditions"},
+				Properties: map[string]apiext.JSONSchemaProps{
+					"conditions": {Type: "array"},
+				},
+			},
+		},
+	}
+}
+
+// testXRD returns an XRD with the version v1alpha1 and testSchema.
+func testXRD(t *testing.T) *xapiext.CompositeResourceDefinition {
+	t.Helper()
+	raw, err := json.Marshal(testSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &xapiext.CompositeResourceDefinition{
+		Spec: xapiext.CompositeResourceDefinitionSpec{
+			Versions: []xapiext.CompositeResourceDefinitionVersion{{
+				Name:   "v1alpha1",
+				Schema: &xapiext.CompositeResourceValidation{OpenAPIV3Schema: runtime.RawExtension{Raw: raw}},
+			}},
+		},
+	}
+}
+
+// versionSchema returns the parsed schema of the first version of xrd.
+func versionSchema(t *testing.T, xrd *xapiext.CompositeResourceDefinition) *apiext.JSONSchemaProps {
+	t.Helper()
+	s := &apiext.JSONSchemaProps{}
+	if err := json.Unmarshal(xrd.Spec.Versions[0].Schema.OpenAPIV3Schema.Raw, s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRemoveFields(t *testing.T) {
+	schema := testSchema()
+	missing := RemoveFields(schema, "spec.compositionRef", "status.conditions", "spec.unknown")
+	if diff := cmp.Diff([]string{"spec.unknown"}, missing); diff != "" {
+		t.Errorf("RemoveFields(...): missing: -want, +got:\n%s", diff)
+	}
+
+	want := testSchema()
+	spec := want.Properties["spec"]
+	delete(spec.Properties, "compositionRef")
+	spec.Required = []string{"parameters"}
+	want.Properties["spec"] = spec
+	status := want.Properties["status"]
+	delete(status.Properties, "conditions")
+	status.Required = nil
+	want.Properties["status"] = status
+	if diff := cmp.Diff(want, schema); diff != "" {
+		t.Errorf("RemoveFields(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestStripCrossplaneFields(t *testing.T) {
+	xrd := testXRD(t)
+	if err := (StripCrossplaneFields{}).ApplyToXRD(xrd, "v1alpha1"); err != nil {
+		t.Fatalf("ApplyToXRD(...): %v", err)
+	}
+	got := versionSchema(t, xrd)
+	if diff := cmp.Diff([]string{"parameters"}, got.Properties["spec"].Required); diff != "" {
+		t.Errorf("ApplyToXRD(...): spec.required: -want, +got:\n%s", diff)
+	}
+	if _, ok := got.Properties["status"].Properties["conditions"]; ok {
+		t.Errorf("ApplyToXRD(...): status.conditions has not been removed")
+	}
+	if err := (StripCrossplaneFields{}).ApplyToXRD(xrd, "v1"); err == nil {
+		t.Errorf("ApplyToXRD(...): want error for unknown version")
+	}
+}
+
+func TestStripFields(t *testing.T) {
+	xrd := testXRD(t)
+	if err := (StripFields{"spec.parameters"}).ApplyToXRD(xrd, "v1alpha1"); err != nil {
+		t.Fatalf("ApplyToXRD(...): %v", err)
+	}
+	if _, ok := versionSchema(t, xrd).Properties["spec"].Properties["parameters"]; ok {
+		t.Errorf("ApplyToXRD(...): spec.parameters has not been removed")
+	}
+
+	want := "schema of version v1alpha1 has no fields spec.unknown"
+	if err := (StripFields{"spec.unknown"}).ApplyToXRD(testXRD(t), "v1alpha1"); err == nil || err.Error() != want {
+		t.Errorf("ApplyToXRD(...): want error %q, got %v", want, err)
+	}
+}
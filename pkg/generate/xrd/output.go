@@ -0,0 +1,276 @@
+package xrd
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/compat"
+	"github.com/mistermx/crossbuilder/pkg/generate/diff"
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+	"github.com/mistermx/crossbuilder/pkg/generate/index"
+	"github.com/mistermx/crossbuilder/pkg/generate/kustomize"
+	xbuilderio "github.com/mistermx/crossbuilder/pkg/generate/utils/io"
+	"github.com/mistermx/crossbuilder/pkg/generate/verify"
+)
+
+const (
+	errFmtOutdatedFile = "%s is out of date"
+	errFmtMissingFile  = "%s does not exist"
+)
+
+// finishingOutputRule is an output rule that needs all generated files at
+// once. The xrd generator renders all files in memory and passes them to
+// finish instead of opening them one by one.
+type finishingOutputRule interface {
+	genall.OutputRule
+	finish(files map[string][]byte) error
+}
+
+// +controllertools:marker:generateHelp:category=""
+
+// OutputToDirectory writes each generated file to the given directory.
+// Files are written atomically, so readers never see partially written
+// files.
+type OutputToDirectory string
+
+// Open returns a writer for the given file in the directory.
+func (o OutputToDirectory) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	return OutputToFileSystem{FS: filesystem.NewOS(string(o))}.Open(pkg, itemPath)
+}
+
+// OutputToFileSystem writes each generated file to the given file system.
+// It is not available on the command line but allows library users to
+// capture all files, e.g. using filesystem.NewMemory.
+type OutputToFileSystem struct {
+	FS filesystem.FS
+}
+
+// Open returns a writer that writes the file to the file system on close.
+func (o OutputToFileSystem) Open(_ *loader.Package, itemPath string) (io.WriteCloser, error) {
+	return xbuilderio.NewOnCloseWriter(nil, func(r io.Reader, _ int64) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return errors.Wrap(err, errReadResult)
+		}
+		return o.FS.WriteFile(filepath.ToSlash(itemPath), data)
+	}), nil
+}
+
+// +controllertools:marker:generateHelp:category=""
+
+// VerifyDirectory does not write anything but fails if the generated files
+// differ from the files in the given directory.
+//
+// When used with the xrd generator, all differing, missing and extra XRD
+// files are reported at once. If the directory has an index file, like the
+// one written by KustomizeDirectory, only files listed in it can be extra.
+// Otherwise the directory must only contain generated files and every
+// other YAML file is reported as extra.
+type VerifyDirectory string
+
+// Open returns a writer that compares the written content with the
+// existing file on close.
+func (o VerifyDirectory) Open(_ *loader.Package, itemPath string) (io.WriteCloser, error) {
+	return xbuilderio.NewOnCloseWriter(nil, func(r io.Reader, _ int64) error {
+		generated, err := io.ReadAll(r)
+		if err != nil {
+			return errors.Wrap(err, errReadResult)
+		}
+		existing, err := filesystem.NewOS(string(o)).ReadFile(filepath.ToSlash(itemPath))
+		if errors.Is(err, fs.ErrNotExist) {
+			return errors.Errorf(errFmtMissingFile, itemPath)
+		}
+		if err != nil {
+			return err
+		}
+		equal := bytes.Equal(generated, existing)
+		if !equal && verify.IsYAMLFile(itemPath) {
+			if equal, err = verify.Equal(generated, existing); err != nil {
+				return err
+			}
+		}
+		if !equal {
+			return errors.Errorf(errFmtOutdatedFile, itemPath)
+		}
+		return nil
+	}), nil
+}
+
+// finish compares the given files with the XRD files in the directory.
+func (o VerifyDirectory) finish(files map[string][]byte) error {
+	fsys := filesystem.NewOS(string(o))
+	isOwned, err := ownedFileFilter(fsys)
+	if err != nil {
+		return err
+	}
+	res, err := verify.FS(fsys, files, isOwned)
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// +controllertools:marker:generateHelp:category=""
+
+// DiffDirectory does not write anything but prints the semantic differences
+// between the generated files and the files in the given directory to
+// standard-out.
+type DiffDirectory struct {
+	// Dir is the directory to compare with.
+	Dir string
+
+	// Format is the output format, either text or json.
+	Format string `marker:",optional"`
+}
+
+// Open returns a writer that prints the differences to the existing file on
+// close.
+func (o DiffDirectory) Open(_ *loader.Package, itemPath string) (io.WriteCloser, error) {
+	return xbuilderio.NewOnCloseWriter(nil, func(r io.Reader, _ int64) error {
+		generated, err := io.ReadAll(r)
+		if err != nil {
+			return errors.Wrap(err, errReadResult)
+		}
+		fd, err := diff.FileFS(filesystem.NewOS(o.Dir), filepath.ToSlash(itemPath), generated)
+		if err != nil {
+			return err
+		}
+		report := &diff.Report{}
+		if fd != nil {
+			report.Files = append(report.Files, *fd)
+		}
+		return report.Write(os.Stdout, diff.Format(o.Format))
+	}), nil
+}
+
+// finish prints the differences of all files at once. Like
+// VerifyDirectory, only files listed in the index of the directory are
+// reported as extra if there is one.
+func (o DiffDirectory) finish(files map[string][]byte) error {
+	fsys := filesystem.NewOS(o.Dir)
+	isOwned, err := ownedFileFilter(fsys)
+	if err != nil {
+		return err
+	}
+	report, err := diff.FS(fsys, files, isOwned)
+	if err != nil {
+		return err
+	}
+	return report.Write(os.Stdout, diff.Format(o.Format))
+}
+
+// ownedFileFilter returns a function that reports whether an existing file
+// of fsys is expected to be generated. If fsys has an index file, only the
+// files listed in it are. Otherwise all YAML files except kustomizations
+// are.
+func ownedFileFilter(fsys fs.FS) (func(path string) bool, error) {
+	return index.OwnedFilter(fsys, kustomize.IsResourceFile)
+}
+
+// +controllertools:marker:generateHelp:category=""
+
+// KustomizeDirectory writes the generated files to the given directory and
+// maintains a kustomization.yaml that lists them. The written files are
+// recorded in an index file, and files recorded by a previous run that
+// have not been written again are removed. Hand-written files are never
+// removed.
+type KustomizeDirectory struct {
+	// Dir is the directory to write to.
+	Dir string
+
+	// CommonLabels are added to the kustomization.
+	CommonLabels map[string]string `marker:",optional"`
+}
+
+// Open returns a writer for the given file in the directory.
+func (o KustomizeDirectory) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	return OutputToDirectory(o.Dir).Open(pkg, itemPath)
+}
+
+// finish writes all files, removes stale files and updates the
+// kustomization afterwards.
+func (o KustomizeDirectory) finish(files map[string][]byte) error {
+	fsys := filesystem.NewOS(o.Dir)
+	written := make([]string, 0, len(files))
+	for path, content := range files {
+		name := filepath.ToSlash(path)
+		if err := fsys.WriteFile(name, content); err != nil {
+			return err
+		}
+		written = append(written, name)
+	}
+	if err := index.Prune(fsys, written); err != nil {
+		return err
+	}
+	return kustomize.UpdateFS(fsys, written, kustomize.Options{CommonLabels: o.CommonLabels})
+}
+
+// +controllertools:marker:generateHelp:category=""
+
+// CompatDirectory does not write anything but prints the schema changes
+// between the XRDs in the given directory and the generated XRDs to
+// standard-out and classifies them as compatible or breaking.
+type CompatDirectory struct {
+	// Dir is the directory that contains the previously generated XRDs.
+	Dir string
+
+	// Revision compares with the XRDs of Dir at the given git revision,
+	// e.g. origin/main, instead of the working tree.
+	Revision string `marker:",optional"`
+
+	// Strict fails if there are breaking changes.
+	Strict bool `marker:",optional"`
+
+	// Removed reports XRDs of Dir that have not been generated as breaking
+	// change. Only enable it if paths contains all API packages, otherwise
+	// XRDs of packages that have not been loaded are reported as well.
+	Removed bool `marker:",optional"`
+
+	// Format is the output format, either text or json.
+	Format string `marker:",optional"`
+}
+
+// Open returns a writer that compares the XRDs of a single file on close.
+func (o CompatDirectory) Open(_ *loader.Package, itemPath string) (io.WriteCloser, error) {
+	return xbuilderio.NewOnCloseWriter(nil, func(r io.Reader, _ int64) error {
+		generated, err := io.ReadAll(r)
+		if err != nil {
+			return errors.Wrap(err, errReadResult)
+		}
+		return o.finish(map[string][]byte{itemPath: generated})
+	}), nil
+}
+
+// finish compares the XRDs of all files at once.
+func (o CompatDirectory) finish(files map[string][]byte) error {
+	var fsys fs.FS = filesystem.NewOS(o.Dir)
+	if o.Revision != "" {
+		var err error
+		if fsys, err = compat.GitRevision(o.Dir, o.Revision); err != nil {
+			return err
+		}
+	}
+	report, err := compat.FS(fsys, files, compat.Options{Removed: o.Removed})
+	if err != nil {
+		return err
+	}
+	if err := report.Write(os.Stdout, diff.Format(o.Format)); err != nil {
+		return err
+	}
+	if o.Strict {
+		return report.Err()
+	}
+	return nil
+}
+
+var _ finishingOutputRule = VerifyDirectory("")
+var _ finishingOutputRule = DiffDirectory{}
+var _ finishingOutputRule = KustomizeDirectory{}
+var _ finishingOutputRule = CompatDirectory{}
@@ -0,0 +1,111 @@
+package xrd
+
+import (
+	"io/fs"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/filesystem"
+	"github.com/mistermx/crossbuilder/pkg/generate/index"
+	"github.com/mistermx/crossbuilder/pkg/generate/kustomize"
+)
+
+func TestKustomizeDirectoryFinish(t *testing.T) {
+	dir := t.TempDir()
+	fsys := filesystem.NewOS(dir)
+	existing := map[string]string{
+		"stale.yaml":        "kind: CompositeResourceDefinition\n",
+		"hand-written.yaml": "kind: Composition\n",
+	}
+	for name, content := range existing {
+		if err := fsys.WriteFile(name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := index.Write(fsys, []string{"stale.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+
+	o := KustomizeDirectory{Dir: dir}
+	err := o.finish(map[string][]byte{
+		"b.yaml": []byte("kind: CompositeResourceDefinition\n"),
+		"a.yaml": []byte("kind: CompositeResourceDefinition\n"),
+	})
+	if err != nil {
+		t.Fatalf("finish(...): %v", err)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	want := []string{index.FileName, "a.yaml", "b.yaml", "hand-written.yaml", kustomize.FileName}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("finish(...): files: -want, +got:\n%s", diff)
+	}
+
+	b, err := fsys.ReadFile(kustomize.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := struct {
+		Resources []string `json:"resources"`
+	}{}
+	if err := yaml.Unmarshal(b, &k); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a.yaml", "b.yaml"}, k.Resources); diff != "" {
+		t.Errorf("finish(...): resources: -want, +got:\n%s", diff)
+	}
+}
+
+func TestVerifyDirectoryFinish(t *testing.T) {
+	xrd := []byte("kind: CompositeResourceDefinition\n")
+	cases := map[string]struct {
+		reason  string
+		index   []string
+		wantErr bool
+	}{
+		"Exclusive": {
+			reason:  "Without an index the directory must only contain generated files, so hand-written YAML files are extra.",
+			wantErr: true,
+		},
+		"Index": {
+			reason: "With an index only files listed in it can be extra, so hand-written YAML files are ignored.",
+			index:  []string{"a.yaml"},
+		},
+		"IndexStale": {
+			reason:  "Files listed in the index that are no longer generated are extra.",
+			index:   []string{"a.yaml", "hand-written.yaml"},
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			fsys := filesystem.NewOS(dir)
+			for _, f := range []string{"a.yaml", "hand-written.yaml"} {
+				if err := fsys.WriteFile(f, xrd); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.index != nil {
+				if err := index.Write(fsys, tc.index); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := VerifyDirectory(dir).finish(map[string][]byte{"a.yaml": xrd})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("\n%s\nfinish(...): want error %t, got %v", tc.reason, tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,7 +1,13 @@
 package xrd
 
 import (
+	"go/ast"
+	"sort"
+	"strings"
+
 	xapiext "github.com/crossplane/crossplane/apis/apiextensions/v1"
+	"github.com/pkg/errors"
+	apiext "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-tools/pkg/crd"
 	"sigs.k8s.io/controller-tools/pkg/loader"
@@ -13,6 +19,32 @@ type XRDMarker interface { // nolint:golint
 	ApplyToXRD(xrd *xapiext.CompositeResourceDefinition, version string) error
 }
 
+// ClaimMarker defines a marker that only applies to the claim CRD
+// Crossplane generates for an XRD.
+type ClaimMarker interface {
+	ApplyToClaim(claim *apiext.CustomResourceDefinition, version string) error
+}
+
+// KeyedMarker is a marker that sets keys, like labels. Every key must only
+// be set once per type.
+type KeyedMarker interface {
+	MarkerKeys() []string
+}
+
+// ValidatedMarker is a marker whose value can be checked on its own, like
+// an enum. It is validated when the markers of a type are collected, before
+// any XRD is assembled.
+type ValidatedMarker interface {
+	Validate() error
+}
+
+const (
+	errFmtDuplicateKey        = "duplicate key %q for marker +%s"
+	errFmtInvalidMarkers      = "XRD %s has invalid markers"
+	errFmtInvalidClaimMarkers = "claim CRD of XRD %s has invalid markers"
+	errInvalidMarkerValues    = "markers have invalid values"
+)
+
 // PackageOverride overrides the loading of some package
 // (potentially setting custom schemata, etc).  It must
 // call AddPackage if it wants to continue with the default
@@ -37,6 +69,10 @@ type Parser struct {
 
 	// packages marks packages as loaded, to avoid re-loading them.
 	packages map[*loader.Package]struct{}
+
+	// invalidMarkers is true if a ValidatedMarker of any indexed type is
+	// invalid.
+	invalidMarkers bool
 }
 
 func (p *Parser) init() {
@@ -84,11 +120,34 @@ func (p *Parser) indexTypes(pkg *loader.Package) {
 		}
 
 		p.Types[ident] = info
+		p.validateMarkers(pkg, info)
 	}); err != nil {
 		pkg.AddError(err)
 	}
 }
 
+// validateMarkers validates all ValidatedMarkers of the given type. Errors
+// are added to pkg at the position of the marker.
+func (p *Parser) validateMarkers(pkg *loader.Package, info *markers.TypeInfo) {
+	names := make([]string, 0, len(info.Markers))
+	for name := range info.Markers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for i, val := range info.Markers[name] {
+			validated, ok := val.(ValidatedMarker)
+			if !ok {
+				continue
+			}
+			if err := validated.Validate(); err != nil {
+				pkg.AddError(loader.ErrFromNode(err, markerNode(info, name, i)))
+				p.invalidMarkers = true
+			}
+		}
+	}
+}
+
 // NeedCRDFor lives off in spec.go
 
 // AddPackage indicates that types and type-checking information is needed
@@ -122,8 +181,48 @@ func (p *Parser) NeedPackage(pkg *loader.Package) {
 	p.AddPackage(pkg)
 }
 
-// ApplyForXRD applies all markers to the generated XRD.
-func (p *Parser) ApplyForXRD(xrd *xapiext.CompositeResourceDefinition) {
+// ApplyForXRD applies all markers to the generated XRD. Markers that fail
+// to apply are reported at their position in the package and result in an
+// error, so callers can abort before writing an incomplete XRD.
+func (p *Parser) ApplyForXRD(xrd *xapiext.CompositeResourceDefinition) error {
+	ok := p.applyMarkers(xrd,
+		func(val interface{}) bool {
+			_, isXRDMarker := val.(XRDMarker)
+			return isXRDMarker
+		},
+		func(val interface{}, version string) error {
+			return val.(XRDMarker).ApplyToXRD(xrd, version)
+		},
+	)
+	if !ok {
+		return errors.Errorf(errFmtInvalidMarkers, xrd.GetName())
+	}
+	return nil
+}
+
+// ApplyForClaim applies all claim markers to the claim CRD of the given XRD.
+// Like ApplyForXRD, it returns an error if any marker fails to apply.
+func (p *Parser) ApplyForClaim(xrd *xapiext.CompositeResourceDefinition, claim *apiext.CustomResourceDefinition) error {
+	ok := p.applyMarkers(xrd,
+		func(val interface{}) bool {
+			_, isClaimMarker := val.(ClaimMarker)
+			return isClaimMarker
+		},
+		func(val interface{}, version string) error {
+			return val.(ClaimMarker).ApplyToClaim(claim, version)
+		},
+	)
+	if !ok {
+		return errors.Errorf(errFmtInvalidClaimMarkers, xrd.GetName())
+	}
+	return nil
+}
+
+// applyMarkers calls apply for all accepted markers of the types of the
+// XRD. Errors are added to the package of the marker. It returns false if
+// any marker failed.
+func (p *Parser) applyMarkers(xrd *xapiext.CompositeResourceDefinition, accepts func(val interface{}) bool, apply func(val interface{}, version string) error) bool {
+	ok := true
 	packages := []*loader.Package{}
 	for pkg, gv := range p.GroupVersions {
 		if gv.Group != xrd.Spec.Group {
@@ -131,6 +230,9 @@ func (p *Parser) ApplyForXRD(xrd *xapiext.CompositeResourceDefinition) {
 		}
 		packages = append(packages, pkg)
 	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].PkgPath < packages[j].PkgPath
+	})
 
 	// apply markers
 	for _, pkg := range packages {
@@ -141,16 +243,96 @@ func (p *Parser) ApplyForXRD(xrd *xapiext.CompositeResourceDefinition) {
 		}
 		ver := p.GroupVersions[pkg].Version
 
-		for _, markerVals := range typeInfo.Markers {
-			for _, val := range markerVals {
-				xrdMarker, isXRDMarker := val.(XRDMarker)
-				if !isXRDMarker {
+		// markers are applied in a stable order, so that markers which
+		// extend the same field, like claimNames and claimCategories,
+		// always produce the same XRD.
+		names := make([]string, 0, len(typeInfo.Markers))
+		for name := range typeInfo.Markers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			markerVals := typeInfo.Markers[name]
+			seen := map[string]bool{}
+			for i, val := range markerVals {
+				if !accepts(val) {
 					continue
 				}
-				if err := xrdMarker.ApplyToXRD(xrd, ver); err != nil {
-					pkg.AddError(loader.ErrFromNode(err /* an okay guess */, typeInfo.RawSpec))
+				if keyed, isKeyed := val.(KeyedMarker); isKeyed {
+					duplicate := false
+					for _, key := range keyed.MarkerKeys() {
+						if seen[key] {
+							pkg.AddError(loader.ErrFromNode(errors.Errorf(errFmtDuplicateKey, key, name), markerNode(typeInfo, name, i)))
+							duplicate = true
+							ok = false
+						}
+						seen[key] = true
+					}
+					if duplicate {
+						continue
+					}
 				}
+				if err := apply(val, ver); err != nil {
+					pkg.AddError(loader.ErrFromNode(err, markerNode(typeInfo, name, i)))
+					ok = false
+				}
+			}
+		}
+	}
+	return ok
+}
+
+// markerNode returns the comment of the i-th occurrence of the named marker
+// of a type. If it cannot be found, the type spec is returned.
+func markerNode(typeInfo *markers.TypeInfo, name string, i int) ast.Node {
+	// Markers of a type are collected from all comments between the previous
+	// declaration and the type spec.
+	start := typeInfo.RawFile.Name.End()
+	for _, decl := range typeInfo.RawFile.Decls {
+		if decl.End() < typeInfo.RawDecl.Pos() && decl.End() > start {
+			start = decl.End()
+		}
+	}
+	comments := []*ast.Comment{}
+	for _, group := range typeInfo.RawFile.Comments {
+		if group.Pos() < start || group.End() > typeInfo.RawSpec.Pos() {
+			continue
+		}
+		for _, c := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			rest, isMarker := strings.CutPrefix(text, "+"+name)
+			if isMarker && (rest == "" || rest[0] == ':' || rest[0] == '=') {
+				comments = append(comments, c)
 			}
 		}
 	}
+	if i < len(comments) {
+		return comments[i]
+	}
+	return typeInfo.RawSpec
+}
+
+// SourceType returns the Go type the given XRD has been generated from in
+// the form <package path>.<type name>. The type of the referenceable
+// version is preferred.
+func (p *Parser) SourceType(xrd *xapiext.CompositeResourceDefinition) string {
+	source := ""
+	for pkg, gv := range p.GroupVersions {
+		if gv.Group != xrd.Spec.Group {
+			continue
+		}
+		if p.Types[crd.TypeIdent{Package: pkg, Name: xrd.Spec.Names.Kind}] == nil {
+			continue
+		}
+		name := pkg.PkgPath + "." + xrd.Spec.Names.Kind
+		for _, v := range xrd.Spec.Versions {
+			if v.Name == gv.Version && v.Referenceable {
+				return name
+			}
+		}
+		if source == "" || name < source {
+			source = name
+		}
+	}
+	return source
 }
@@ -0,0 +1,28 @@
+// Package celinvalid contains an API type with a CEL rule that refers to an
+// unknown field.
+// +groupName=test.example.org
+// +versionName=v1alpha1
+package celinvalid
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type XTestSpec struct {
+	// +kubebuilder:validation:XValidation:rule="self.min <= self.maximum"
+	Range XTestRange `json:"range"`
+}
+
+type XTestRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+type XTest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec XTestSpec `json:"spec"`
+}
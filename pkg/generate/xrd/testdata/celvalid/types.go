@@ -0,0 +1,23 @@
+// Package celvalid contains an API type with a valid CEL rule.
+// +groupName=test.example.org
+// +versionName=v1alpha1
+package celvalid
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:validation:XValidation:rule="self.min <= self.max",message="min must not exceed max"
+type XTestSpec struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+type XTest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec XTestSpec `json:"spec"`
+}
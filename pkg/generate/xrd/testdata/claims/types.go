@@ -0,0 +1,26 @@
+// Package claims contains an API type with claim specific printer columns
+// and categories.
+// +groupName=test.example.org
+// +versionName=v1alpha1
+package claims
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type XTestSpec struct {
+	Size int `json:"size"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="SIZE",type="integer",JSONPath=".spec.size"
+// +crossbuilder:generate:xrd:claimNames:kind=Test,plural=tests,categories={crossplane}
+// +crossbuilder:generate:xrd:claimCategories={platform,crossplane}
+// +crossbuilder:generate:xrd:claimPrintColumn:name="NAMESPACE-SIZE",type="integer",JSONPath=".spec.size"
+type XTest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec XTestSpec `json:"spec"`
+}
@@ -0,0 +1,23 @@
+// Package invalidpolicy contains an API type with an invalid composite
+// delete policy.
+// +groupName=test.example.org
+// +versionName=v1alpha1
+package invalidpolicy
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type XTestSpec struct {
+	Size int `json:"size"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +crossbuilder:generate:xrd:defaultCompositeDeletePolicy:policy=Later
+type XTest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec XTestSpec `json:"spec"`
+}
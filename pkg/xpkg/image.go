@@ -0,0 +1,239 @@
+package xpkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errBuildLayer = "failed to build package layer"
+	errWriteImage = "failed to write image"
+
+	// AnnotationKey is the annotation of the layer that contains the
+	// package stream. Crossplane also reads it from the image config labels
+	// in the form io.crossplane.xpkg:<layer digest>.
+	AnnotationKey = "io.crossplane.xpkg"
+
+	// PackageAnnotation is the value of AnnotationKey for the package
+	// layer.
+	PackageAnnotation = "base"
+
+	annotationRefName = "org.opencontainers.image.ref.name"
+
+	mediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	mediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar"
+
+	ociLayoutFile    = "oci-layout"
+	ociLayoutVersion = "1.0.0"
+	indexFile        = "index.json"
+	dockerManifest   = "manifest.json"
+	blobsDir         = "blobs/"
+	blobsSHA256Dir   = "blobs/sha256/"
+)
+
+// epoch is the modification time of all files in the image, so images only
+// depend on their content.
+var epoch = time.Unix(0, 0).UTC()
+
+// ImageOptions configure the image written by WriteImage.
+type ImageOptions struct {
+	// Tag is an optional image reference like
+	// xpkg.upbound.io/my-org/my-platform:v0.1.0 that is recorded in the
+	// image.
+	Tag string
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+type index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Manifests     []descriptor `json:"manifests"`
+}
+
+type imageConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Config       struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+	RootFS struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// dockerManifestEntry is an entry of the manifest.json file that is read by
+// tools that load images from tarballs, like crossplane xpkg push.
+type dockerManifestEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+type blob struct {
+	digest  string
+	content []byte
+}
+
+func newBlob(content []byte) blob {
+	sum := sha256.Sum256(content)
+	return blob{
+		digest:  "sha256:" + hex.EncodeToString(sum[:]),
+		content: content,
+	}
+}
+
+func (b blob) path() string {
+	return blobsSHA256Dir + b.digest[len("sha256:"):]
+}
+
+func (b blob) descriptor(mediaType string, annotations map[string]string) descriptor {
+	return descriptor{
+		MediaType:   mediaType,
+		Digest:      b.digest,
+		Size:        int64(len(b.content)),
+		Annotations: annotations,
+	}
+}
+
+// WriteImage writes an image that contains the given package stream as an
+// OCI image layout tarball to w. The tarball also contains a manifest.json
+// file, so it can be loaded like a tarball created by docker save.
+//
+// The image is built offline and is reproducible, i.e. the same stream
+// always results in the same image digest.
+func WriteImage(w io.Writer, stream []byte, opts ImageOptions) error {
+	layerTar, err := tarFiles([]tarFile{{name: StreamFileName, content: stream}})
+	if err != nil {
+		return errors.Wrap(err, errBuildLayer)
+	}
+	layer := newBlob(layerTar)
+
+	cfg := imageConfig{}
+	cfg.Config.Labels = map[string]string{
+		AnnotationKey + ":" + layer.digest: PackageAnnotation,
+	}
+	cfg.RootFS.Type = "layers"
+	// The layer is not compressed, so its digest is also its diff ID.
+	cfg.RootFS.DiffIDs = []string{layer.digest}
+	cfgBlob, err := jsonBlob(cfg)
+	if err != nil {
+		return errors.Wrap(err, errWriteImage)
+	}
+
+	manifestBlob, err := jsonBlob(manifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeManifest,
+		Config:        cfgBlob.descriptor(mediaTypeConfig, nil),
+		Layers: []descriptor{
+			layer.descriptor(mediaTypeLayer, map[string]string{AnnotationKey: PackageAnnotation}),
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, errWriteImage)
+	}
+
+	var refAnnotations map[string]string
+	var repoTags []string
+	if opts.Tag != "" {
+		refAnnotations = map[string]string{annotationRefName: opts.Tag}
+		repoTags = []string{opts.Tag}
+	}
+	indexJSON, err := json.Marshal(index{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeIndex,
+		Manifests:     []descriptor{manifestBlob.descriptor(mediaTypeManifest, refAnnotations)},
+	})
+	if err != nil {
+		return errors.Wrap(err, errWriteImage)
+	}
+	dockerJSON, err := json.Marshal([]dockerManifestEntry{{
+		Config:   cfgBlob.path(),
+		RepoTags: repoTags,
+		Layers:   []string{layer.path()},
+	}})
+	if err != nil {
+		return errors.Wrap(err, errWriteImage)
+	}
+
+	image, err := tarFiles([]tarFile{
+		{name: ociLayoutFile, content: []byte(`{"imageLayoutVersion":"` + ociLayoutVersion + `"}`)},
+		{name: indexFile, content: indexJSON},
+		{name: dockerManifest, content: dockerJSON},
+		{name: blobsDir, dir: true},
+		{name: blobsSHA256Dir, dir: true},
+		{name: cfgBlob.path(), content: cfgBlob.content},
+		{name: layer.path(), content: layer.content},
+		{name: manifestBlob.path(), content: manifestBlob.content},
+	})
+	if err != nil {
+		return errors.Wrap(err, errWriteImage)
+	}
+	_, err = w.Write(image)
+	return errors.Wrap(err, errWriteImage)
+}
+
+func jsonBlob(v interface{}) (blob, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return blob{}, err
+	}
+	return newBlob(b), nil
+}
+
+type tarFile struct {
+	name    string
+	dir     bool
+	content []byte
+}
+
+// tarFiles creates a tar archive of the given files with fixed metadata.
+func tarFiles(files []tarFile) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(f.content)),
+			ModTime:  epoch,
+			Typeflag: tar.TypeReg,
+		}
+		if f.dir {
+			hdr.Mode = 0755
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(f.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
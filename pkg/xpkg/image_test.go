@@ -0,0 +1,118 @@
+package xpkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// readTar returns the content of all regular files of the tarball.
+func readTar(t *testing.T, b []byte) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{}
+	tr := tar.NewReader(bytes.NewReader(b))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = content
+	}
+}
+
+func unmarshalFile(t *testing.T, files map[string][]byte, name string, v interface{}) {
+	t.Helper()
+	content, ok := files[name]
+	if !ok {
+		t.Fatalf("image has no file %s", name)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		t.Fatalf("cannot parse %s: %v", name, err)
+	}
+}
+
+func TestWriteImage(t *testing.T) {
+	stream := []byte("---\nkind: Configuration\n")
+	tag := "xpkg.upbound.io/example/platform:v0.1.0"
+
+	buf := &bytes.Buffer{}
+	if err := WriteImage(buf, stream, ImageOptions{Tag: tag}); err != nil {
+		t.Fatalf("WriteImage(...): %v", err)
+	}
+	files := readTar(t, buf.Bytes())
+
+	if diff := cmp.Diff(`{"imageLayoutVersion":"1.0.0"}`, string(files[ociLayoutFile])); diff != "" {
+		t.Errorf("%s: -want, +got:\n%s", ociLayoutFile, diff)
+	}
+
+	idx := index{}
+	unmarshalFile(t, files, indexFile, &idx)
+	if len(idx.Manifests) != 1 {
+		t.Fatalf("%s: want 1 manifest, got %d", indexFile, len(idx.Manifests))
+	}
+	if got := idx.Manifests[0].Annotations[annotationRefName]; got != tag {
+		t.Errorf("%s: ref name: want %q, got %q", indexFile, tag, got)
+	}
+
+	m := manifest{}
+	unmarshalFile(t, files, blobPath(idx.Manifests[0].Digest), &m)
+	if len(m.Layers) != 1 {
+		t.Fatalf("manifest: want 1 layer, got %d", len(m.Layers))
+	}
+	layer := m.Layers[0]
+	if got := layer.Annotations[AnnotationKey]; got != PackageAnnotation {
+		t.Errorf("manifest: layer annotation: want %q, got %q", PackageAnnotation, got)
+	}
+
+	cfg := imageConfig{}
+	unmarshalFile(t, files, blobPath(m.Config.Digest), &cfg)
+	if got := cfg.Config.Labels[AnnotationKey+":"+layer.Digest]; got != PackageAnnotation {
+		t.Errorf("config: layer label: want %q, got %q", PackageAnnotation, got)
+	}
+
+	layerFiles := readTar(t, files[blobPath(layer.Digest)])
+	if diff := cmp.Diff(string(stream), string(layerFiles[StreamFileName])); diff != "" {
+		t.Errorf("layer: %s: -want, +got:\n%s", StreamFileName, diff)
+	}
+
+	docker := []dockerManifestEntry{}
+	unmarshalFile(t, files, dockerManifest, &docker)
+	want := []dockerManifestEntry{{
+		Config:   blobPath(m.Config.Digest),
+		RepoTags: []string{tag},
+		Layers:   []string{blobPath(layer.Digest)},
+	}}
+	if diff := cmp.Diff(want, docker); diff != "" {
+		t.Errorf("%s: -want, +got:\n%s", dockerManifest, diff)
+	}
+}
+
+func TestWriteImageReproducible(t *testing.T) {
+	a, b := &bytes.Buffer{}, &bytes.Buffer{}
+	for _, buf := range []*bytes.Buffer{a, b} {
+		if err := WriteImage(buf, []byte("---\nkind: Configuration\n"), ImageOptions{}); err != nil {
+			t.Fatalf("WriteImage(...): %v", err)
+		}
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Errorf("WriteImage(...): images of the same stream differ")
+	}
+}
+
+func blobPath(digest string) string {
+	return blobsSHA256Dir + digest[len("sha256:"):]
+}
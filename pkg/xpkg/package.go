@@ -0,0 +1,171 @@
+// Package xpkg assembles Crossplane packages from generated manifests and
+// writes them as OCI images without requiring the Crossplane CLI or a
+// container runtime.
+package xpkg
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/mistermx/crossbuilder/pkg/generate/kustomize"
+	"github.com/mistermx/crossbuilder/pkg/generate/verify"
+)
+
+const (
+	errReadMeta        = "failed to read package metadata"
+	errReadPackageRoot = "failed to read package root"
+	errFmtReadFile     = "failed to read %s"
+	errFmtParseFile    = "failed to parse %s"
+	errWritePackage    = "failed to write package"
+	errFmtUnsupported  = "%s contains a %s, but packages may only contain CompositeResourceDefinitions and Compositions"
+
+	// MetaFileName is the name of the package metadata file in the package
+	// root.
+	MetaFileName = "crossplane.yaml"
+
+	// StreamFileName is the name of the file in the package layer that
+	// contains all manifests.
+	StreamFileName = "package.yaml"
+
+	kindXRD         = "CompositeResourceDefinition"
+	kindComposition = "Composition"
+
+	documentStart = "---"
+)
+
+// document is a single YAML document of the package.
+type document struct {
+	file    string
+	kind    string
+	content []byte
+}
+
+// Stream assembles the package.yaml stream from the package metadata in the
+// crossplane.yaml file in root and all other YAML files in root and its
+// subdirectories. The metadata is followed by all XRDs and all compositions.
+// Objects of the same kind are sorted by file name, so the result only
+// depends on the content of root. Files that contain other objects, e.g.
+// EnvironmentConfigs, claims or claim CRD previews, are an error and must be
+// kept outside of root.
+func Stream(root string) ([]byte, error) {
+	meta, err := os.ReadFile(filepath.Join(root, MetaFileName))
+	if err != nil {
+		return nil, errors.Wrap(err, errReadMeta)
+	}
+
+	docs := []document{}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !verify.IsYAMLFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == MetaFileName || kustomize.IsKustomizationFile(rel) {
+			return nil
+		}
+		fileDocs, err := readDocuments(path, rel)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, fileDocs...)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, errReadPackageRoot)
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		if ki, kj := kindOrder(docs[i].kind), kindOrder(docs[j].kind); ki != kj {
+			return ki < kj
+		}
+		return docs[i].file < docs[j].file
+	})
+
+	buf := &bytes.Buffer{}
+	writeDocument(buf, meta)
+	for _, d := range docs {
+		writeDocument(buf, d.content)
+	}
+	return buf.Bytes(), nil
+}
+
+// readDocuments reads all non-empty YAML documents of the given file.
+func readDocuments(path, rel string) ([]document, error) {
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtReadFile, rel)
+	}
+	defer f.Close() //nolint:errcheck
+
+	docs := []document{}
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(f))
+	for {
+		content, err := reader.Read()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtReadFile, rel)
+		}
+		obj := struct {
+			Kind string `json:"kind"`
+		}{}
+		if err := yaml.Unmarshal(content, &obj); err != nil {
+			return nil, errors.Wrapf(err, errFmtParseFile, rel)
+		}
+		if len(bytes.TrimSpace(content)) == 0 || obj.Kind == "" {
+			// Skip empty documents and documents that only contain
+			// comments.
+			continue
+		}
+		if obj.Kind != kindXRD && obj.Kind != kindComposition {
+			return nil, errors.Errorf(errFmtUnsupported, rel, obj.Kind)
+		}
+		docs = append(docs, document{
+			file:    rel,
+			kind:    obj.Kind,
+			content: content,
+		})
+	}
+}
+
+func kindOrder(kind string) int {
+	if kind == kindXRD {
+		return 0
+	}
+	return 1
+}
+
+func writeDocument(buf *bytes.Buffer, content []byte) {
+	content = bytes.TrimPrefix(bytes.TrimSpace(content), []byte(documentStart))
+	buf.WriteString(documentStart + "\n")
+	buf.Write(bytes.TrimSpace(content))
+	buf.WriteByte('\n')
+}
+
+// Build assembles the package in root and writes it as an image to file.
+func Build(root, file string, opts ImageOptions) error {
+	stream, err := Stream(root)
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteImage(buf, stream, opts); err != nil {
+		return err
+	}
+	return errors.Wrap(os.WriteFile(file, buf.Bytes(), 0644), errWritePackage) //nolint:gosec
+}
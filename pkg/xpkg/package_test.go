@@ -0,0 +1,75 @@
+package xpkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		MetaFileName:                      "apiVersion: meta.pkg.crossplane.io/v1\nkind: Configuration\n",
+		"compositions/b.yaml":             "kind: Composition\nmetadata: {name: b}\n",
+		"compositions/a.yaml":             "# generated\n---\nkind: Composition\nmetadata: {name: a}\n",
+		"xrds/xbuckets.yaml":              "kind: CompositeResourceDefinition\nmetadata: {name: xbuckets}\n",
+		"compositions/kustomization.yaml": "kind: Kustomization\n",
+		"README.md":                       "# Not part of the package\n",
+	})
+
+	got, err := Stream(root)
+	if err != nil {
+		t.Fatalf("Stream(...): %v", err)
+	}
+	want := strings.Join([]string{
+		"---\napiVersion: meta.pkg.crossplane.io/v1\nkind: Configuration",
+		"---\nkind: CompositeResourceDefinition\nmetadata: {name: xbuckets}",
+		"---\nkind: Composition\nmetadata: {name: a}",
+		"---\nkind: Composition\nmetadata: {name: b}",
+	}, "\n") + "\n"
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Stream(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestStreamUnsupportedKind(t *testing.T) {
+	cases := map[string]string{
+		"EnvironmentConfig": "kind: EnvironmentConfig\n",
+		"ClaimPreview":      "kind: CustomResourceDefinition\n",
+		"Claim":             "kind: Bucket\n",
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{
+				MetaFileName: "kind: Configuration\n",
+				"other.yaml": "kind: Composition\n---\n" + content,
+			})
+			if _, err := Stream(root); err == nil || !strings.Contains(err.Error(), "other.yaml") {
+				t.Errorf("Stream(...): want error for other.yaml, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStreamMissingMeta(t *testing.T) {
+	if _, err := Stream(t.TempDir()); err == nil {
+		t.Errorf("Stream(...): want error if %s is missing", MetaFileName)
+	}
+}